@@ -0,0 +1,224 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Msat is an amount in millisatoshis, the unit lightningd computes in.
+type Msat int64
+
+const (
+	MsatPerSat Msat = 1000
+	MsatPerBTC Msat = 100000000000
+)
+
+// amountUnit is the unit amounts are shown in: sat, msat or btc
+var amountUnit = "sat"
+
+var amountUnits = []string{"sat", "msat", "btc"}
+
+func validUnit(unit string) bool {
+	for _, u := range amountUnits {
+		if u == unit {
+			return true
+		}
+	}
+	return false
+}
+
+func Sats(sat int64) Msat {
+	return Msat(sat) * MsatPerSat
+}
+
+// ParseMsat parses the amounts found in lightningd requests and responses:
+// "123msat", "123sat", "0.1btc" and plain integers, which are msat.
+func ParseMsat(s string) (Msat, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch {
+	case strings.HasSuffix(s, "msat"):
+		v, err := strconv.ParseInt(strings.TrimSuffix(s, "msat"), 10, 64)
+		return Msat(v), amountError(s, err)
+	case strings.HasSuffix(s, "sat"):
+		v, err := strconv.ParseInt(strings.TrimSuffix(s, "sat"), 10, 64)
+		return Sats(v), amountError(s, err)
+	case strings.HasSuffix(s, "btc"):
+		return parseBTC(strings.TrimSuffix(s, "btc"))
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	return Msat(v), amountError(s, err)
+}
+
+func amountError(s string, err error) error {
+	if err != nil {
+		return fmt.Errorf("can't parse amount %q", s)
+	}
+	return nil
+}
+
+// parseBTC parses a decimal number of bitcoins without going through floats.
+func parseBTC(s string) (Msat, error) {
+	negative := strings.HasPrefix(s, "-")
+	whole, fraction := strings.TrimPrefix(s, "-"), ""
+	if idx := strings.Index(whole, "."); idx >= 0 {
+		whole, fraction = whole[:idx], whole[idx+1:]
+	}
+	if len(fraction) > 11 || (whole == "" && fraction == "") || !isDigits(whole) || !isDigits(fraction) {
+		return 0, fmt.Errorf("can't parse amount %q", s+"btc")
+	}
+	if whole == "" {
+		whole = "0"
+	}
+	w, err := strconv.ParseInt(whole, 10, 64)
+	if err != nil || w > int64(math.MaxInt64/MsatPerBTC) {
+		return 0, fmt.Errorf("can't parse amount %q", s+"btc")
+	}
+	f := int64(0)
+	if fraction != "" {
+		f, err = strconv.ParseInt(fraction+strings.Repeat("0", 11-len(fraction)), 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("can't parse amount %q", s+"btc")
+		}
+	}
+	m := Msat(w)*MsatPerBTC + Msat(f)
+	if negative {
+		m = -m
+	}
+	return m, nil
+}
+
+func isDigits(s string) bool {
+	for _, c := range s {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// MsatFromResult reads an amount given as a number of msat or as a string.
+func MsatFromResult(value gjson.Result) (Msat, error) {
+	switch value.Type {
+	case gjson.Number:
+		return Msat(value.Int()), nil
+	case gjson.String:
+		return ParseMsat(value.String())
+	case gjson.Null:
+		return 0, errors.New("missing amount")
+	}
+	return 0, fmt.Errorf("can't parse amount %s", value.Raw)
+}
+
+// msatField reads the first of keys present in results. Amounts moved over
+// versions, e.g. from msatoshi_total (integer) to total_msat ("123msat"
+// string, then integer), so callers list them newest first.
+func msatField(results gjson.Result, keys ...string) Msat {
+	for _, key := range keys {
+		value := results.Get(key)
+		if !value.Exists() {
+			continue
+		}
+		if m, err := MsatFromResult(value); err == nil {
+			return m
+		}
+	}
+	return 0
+}
+
+// satOrMsatField reads an amount newer versions give in msat under msatKey
+// and older ones in sat under satKey.
+func satOrMsatField(results gjson.Result, msatKey, satKey string) Msat {
+	if value := results.Get(msatKey); value.Exists() {
+		if m, err := MsatFromResult(value); err == nil {
+			return m
+		}
+	}
+	return Sats(results.Get(satKey).Int())
+}
+
+// msatValue reads an amount which older c-lightning versions report as
+// "123msat" strings and newer ones as plain integers under a *_msat key.
+func msatValue(results gjson.Result, name string) Msat {
+	return msatField(results, name+"_msat", name)
+}
+
+// Sat truncates m to whole satoshis.
+func (m Msat) Sat() int64 {
+	return int64(m / MsatPerSat)
+}
+
+// SatFloat is m in satoshis, the msat being the fraction.
+func (m Msat) SatFloat() float64 {
+	return float64(m) / float64(MsatPerSat)
+}
+
+// BTC formats m as bitcoins without trailing zeros.
+func (m Msat) BTC() string {
+	sign := ""
+	if m < 0 {
+		sign = "-"
+		m = -m
+	}
+	s := fmt.Sprintf("%s%d.%011d", sign, m/MsatPerBTC, m%MsatPerBTC)
+	return strings.TrimSuffix(strings.TrimRight(s, "0"), ".")
+}
+
+// Format formats m in the unit chosen by the user.
+func (m Msat) Format() string {
+	switch amountUnit {
+	case "msat":
+		return formatSats(int64(m))
+	case "btc":
+		return m.BTC()
+	}
+	return formatSats(m.Sat())
+}
+
+// formatAmount formats an amount of sats in the unit chosen by the user.
+func formatAmount(sats int64) string {
+	return Sats(sats).Format()
+}
+
+// Feerate is an on-chain fee rate in sat per 1000 virtual bytes, lightningd's
+// perkb. A virtual byte is 4 weight units, so perkw is a quarter of it.
+type Feerate int64
+
+func FeeratePerKb(perkb int64) Feerate {
+	return Feerate(perkb)
+}
+
+func FeeratePerKw(perkw int64) Feerate {
+	return Feerate(perkw * 4)
+}
+
+func (f Feerate) PerKb() int64 {
+	return int64(f)
+}
+
+func (f Feerate) PerKw() int64 {
+	return int64(f) / 4
+}
+
+func (f Feerate) SatPerVByte() float64 {
+	return float64(f) / 1000
+}
+
+// Fee is the fee of a transaction of vbytes at f.
+func (f Feerate) Fee(vbytes int64) Msat {
+	// sat per 1000 vbytes times vbytes is msat
+	return Msat(int64(f) * vbytes)
+}
+
+// WeightFee is the fee of a transaction of weight units at f.
+func (f Feerate) WeightFee(weight int64) Msat {
+	// perkw times weight is msat
+	return Msat(f.PerKw() * weight)
+}
+
+func (f Feerate) String() string {
+	return strconv.FormatFloat(f.SatPerVByte(), 'f', -1, 64) + " sat/vB"
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseMsat(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Msat
+		wantErr bool
+	}{
+		{"123msat", 123, false},
+		{"123", 123, false},
+		{"0msat", 0, false},
+		{"-5msat", -5, false},
+		{"123sat", 123000, false},
+		{" 42SAT ", 42000, false},
+		{"0.1btc", 10000000000, false},
+		{"1btc", 100000000000, false},
+		{"-1.5btc", -150000000000, false},
+		{".5btc", 50000000000, false},
+		{"0.00000000001btc", 1, false},
+		{"21000000btc", 2100000000000000000, false},
+		{"", 0, true},
+		{"msat", 0, true},
+		{"12.5sat", 0, true},
+		{"1e3", 0, true},
+		{"abc", 0, true},
+	}
+	for _, test := range tests {
+		got, err := ParseMsat(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("ParseMsat(%q) error %v, expected an error: %v", test.in, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("ParseMsat(%q) = %d, expected %d", test.in, got, test.want)
+		}
+	}
+}
+
+func TestParseBTC(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Msat
+		wantErr bool
+	}{
+		{"0", 0, false},
+		{"0.1", 10000000000, false},
+		{"1.", 100000000000, false},
+		{"-1.5", -150000000000, false},
+		{"0.12345678901", 12345678901, false},
+		// msat is the smallest unit, 11 fraction digits
+		{"0.123456789012", 0, true},
+		{"1.000000000000", 0, true},
+		{"", 0, true},
+		{".", 0, true},
+		{"-", 0, true},
+		{"--1", 0, true},
+		{"+1", 0, true},
+		{"1.-5", 0, true},
+		{"1.+5", 0, true},
+		{"1.2.3", 0, true},
+		{"99999999", 0, true},
+	}
+	for _, test := range tests {
+		got, err := parseBTC(test.in)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseBTC(%q) error %v, expected an error: %v", test.in, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseBTC(%q) = %d, expected %d", test.in, got, test.want)
+		}
+	}
+}
+
+func TestMsatBTC(t *testing.T) {
+	tests := []struct {
+		in   Msat
+		want string
+	}{
+		{0, "0"},
+		{1, "0.00000000001"},
+		{1000, "0.00000001"},
+		{10000000000, "0.1"},
+		{100000000000, "1"},
+		{150000000000, "1.5"},
+		{-150000000000, "-1.5"},
+		{-1, "-0.00000000001"},
+		{2100000000000000000, "21000000"},
+	}
+	for _, test := range tests {
+		if got := test.in.BTC(); got != test.want {
+			t.Errorf("Msat(%d).BTC() = %q, expected %q", test.in, got, test.want)
+		}
+		// what BTC writes parses back to the same amount
+		if back, err := ParseMsat(test.in.BTC() + "btc"); err != nil || back != test.in {
+			t.Errorf("ParseMsat(%q) = %d, %v, expected %d", test.in.BTC()+"btc", back, err, test.in)
+		}
+	}
+}
+
+func TestMsatFormat(t *testing.T) {
+	defer func(unit string) { amountUnit = unit }(amountUnit)
+
+	tests := []struct {
+		unit string
+		in   Msat
+		want string
+	}{
+		{"sat", 1234567, "1,234"},
+		{"msat", 1234567, "1,234,567"},
+		{"btc", 1234567, "0.00001234567"},
+	}
+	for _, test := range tests {
+		amountUnit = test.unit
+		if got := test.in.Format(); got != test.want {
+			t.Errorf("Msat(%d).Format() in %s = %q, expected %q", test.in, test.unit, got, test.want)
+		}
+	}
+}
+
+func TestFeerate(t *testing.T) {
+	tests := []struct {
+		feerate     Feerate
+		perKb       int64
+		perKw       int64
+		satPerVByte float64
+		str         string
+	}{
+		{FeeratePerKb(1000), 1000, 250, 1, "1 sat/vB"},
+		{FeeratePerKw(253), 1012, 253, 1.012, "1.012 sat/vB"},
+		{FeeratePerKb(25000), 25000, 6250, 25, "25 sat/vB"},
+		{FeeratePerKw(7500), 30000, 7500, 30, "30 sat/vB"},
+	}
+	for _, test := range tests {
+		if got := test.feerate.PerKb(); got != test.perKb {
+			t.Errorf("%v PerKb() = %d, expected %d", test.feerate, got, test.perKb)
+		}
+		if got := test.feerate.PerKw(); got != test.perKw {
+			t.Errorf("%v PerKw() = %d, expected %d", test.feerate, got, test.perKw)
+		}
+		if got := test.feerate.SatPerVByte(); got != test.satPerVByte {
+			t.Errorf("%v SatPerVByte() = %v, expected %v", test.feerate, got, test.satPerVByte)
+		}
+		if got := test.feerate.String(); got != test.str {
+			t.Errorf("String() = %q, expected %q", got, test.str)
+		}
+	}
+
+	// 141 vbytes, a 1 input 2 outputs segwit transaction
+	if got := FeeratePerKb(2000).Fee(141); got != Sats(282) {
+		t.Errorf("Fee(141) at 2 sat/vB = %d msat, expected 282 sat", got)
+	}
+	// 4 weight units are a vbyte
+	if got := FeeratePerKb(2000).WeightFee(564); got != Sats(282) {
+		t.Errorf("WeightFee(564) at 2 sat/vB = %d msat, expected 282 sat", got)
+	}
+}
+
+func TestLeaseCost(t *testing.T) {
+	ad := &OptionWillFund{
+		leaseFeeBaseMsat: 1500,
+		leaseFeeBasis:    65,
+		fundingWeight:    666,
+	}
+	// 1500 msat base, 0.65% of 1M sats, 666 weight units at 2 sat/vB
+	want := Msat(1500) + Sats(6500) + Msat(666*500)
+	if got := leaseCost(ad, Sats(1000000), FeeratePerKb(2000)); got != want {
+		t.Errorf("leaseCost = %d msat, expected %d", got, want)
+	}
+}
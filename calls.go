@@ -0,0 +1,224 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultCallTimeout is how long calls to methods without a timeout of their
+// own wait for lightningd, set with --call-timeout.
+var defaultCallTimeout = 30 * time.Second
+
+// callTimeouts are how long calls to each method wait for lightningd. Quick
+// lookups fail fast, payments and on-chain operations can take much longer.
+// They can be changed with --timeouts.
+var callTimeouts = map[string]time.Duration{
+	"getinfo":          5 * time.Second,
+	"listconfigs":      5 * time.Second,
+	"feerates":         10 * time.Second,
+	"ping":             10 * time.Second,
+	"connect":          time.Minute,
+	"listnodes":        time.Minute,
+	"listchannels":     time.Minute,
+	"close":            10 * time.Minute,
+	"multifundchannel": 5 * time.Minute,
+	"pay":              10 * time.Minute,
+	"keysend":          10 * time.Minute,
+	"waitsendpay":      10 * time.Minute,
+}
+
+func callTimeout(method string) time.Duration {
+	if timeout, exists := callTimeouts[method]; exists {
+		return timeout
+	}
+	return defaultCallTimeout
+}
+
+// paymentMethods can't be stopped waiting for from the calls in flight:
+// lightningd would carry on with the payment while it could be sent again.
+// They give up after retryFor instead.
+var paymentMethods = map[string]bool{
+	"pay":         true,
+	"keysend":     true,
+	"waitsendpay": true,
+}
+
+// retryFor is how long, in seconds, lightningd may keep trying a payment
+// made with method. It gives up a little before cluster stops waiting, so no
+// payment goes through unnoticed after its call timed out.
+func retryFor(method string) int {
+	seconds := int(callTimeout(method) * 9 / 10 / time.Second)
+	if seconds < 1 {
+		return 1
+	}
+	return seconds
+}
+
+// parseTimeouts reads timeouts such as "getinfo=2s,pay=30m" into
+// callTimeouts.
+func parseTimeouts(s string) error {
+	for _, item := range strings.Split(s, ",") {
+		if strings.TrimSpace(item) == "" {
+			continue
+		}
+		parts := strings.SplitN(item, "=", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("expected method=duration, got %q", item)
+		}
+		timeout, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+		if err != nil {
+			return fmt.Errorf("%s: %s", parts[0], err)
+		}
+		callTimeouts[strings.TrimSpace(parts[0])] = timeout
+	}
+	return nil
+}
+
+// InFlightCall is a call waiting for lightningd to reply.
+type InFlightCall struct {
+	id       int
+	method   string
+	params   string
+	started  time.Time
+	deadline time.Time
+	cancel   context.CancelFunc
+}
+
+var inFlight = make(map[int]*InFlightCall)
+var inFlightLock sync.Mutex
+var lastCallID = 0
+
+// startCall registers a call until the returned function is called.
+func startCall(method string, params []interface{}, deadline time.Time, cancel context.CancelFunc) func() {
+	inFlightLock.Lock()
+	defer inFlightLock.Unlock()
+
+	lastCallID += 1
+	id := lastCallID
+	var formatted []string
+	for _, param := range params {
+		formatted = append(formatted, fmt.Sprint(param))
+	}
+	inFlight[id] = &InFlightCall{id, method, strings.Join(formatted, " "), time.Now(), deadline, cancel}
+	return func() {
+		inFlightLock.Lock()
+		delete(inFlight, id)
+		inFlightLock.Unlock()
+	}
+}
+
+// inFlightCalls returns the calls in flight, oldest first.
+func inFlightCalls() []InFlightCall {
+	inFlightLock.Lock()
+	defer inFlightLock.Unlock()
+
+	var calls []InFlightCall
+	for _, c := range inFlight {
+		calls = append(calls, *c)
+	}
+	sort.Slice(calls, func(i, j int) bool { return calls[i].id < calls[j].id })
+	return calls
+}
+
+// callAsync calls method in the background, so the UI stays responsive and
+// the call can be cancelled from the in flight list, then hands the result
+// to done on the UI's event loop.
+func (ui *UI) callAsync(ctx context.Context, done func(gjson.Result, error), method string, params ...interface{}) {
+	go func() {
+		results, err := callContext(ctx, ui, method, params...)
+		ui.app.QueueUpdateDraw(func() {
+			done(results, err)
+		})
+	}()
+}
+
+// NewInFlightPage lists the calls waiting for lightningd, Enter stops waiting
+// for the selected one unless it's a payment.
+func (ui *UI) NewInFlightPage() tview.Primitive {
+	t := NewTable()
+	t.SetTitle(" Calls in flight ")
+	t.SetBorder(true)
+	t.SetBorderColor(BorderColor)
+	t.SetSelectable(true, false)
+
+	t.AddColumnHeader("[bold]method", tview.AlignLeft)
+	t.AddColumnHeader("running", tview.AlignRight)
+	t.AddColumnHeader("timeout in", tview.AlignRight)
+	t.AddColumnHeader("params", tview.AlignLeft)
+	t.Separator(10)
+	rowOffset := t.GetRowCount()
+	t.SetFixed(rowOffset, 0)
+	t.Select(rowOffset, 0)
+
+	var calls []InFlightCall
+	fill := func() {
+		calls = inFlightCalls()
+		for row := t.GetRowCount() - 1; row >= rowOffset; row-- {
+			t.RemoveRow(row)
+		}
+		now := time.Now()
+		for row, c := range calls {
+			currentRow := row + rowOffset
+			t.SetCell(currentRow, 0, tview.NewTableCell("[greenyellow]"+c.method))
+			t.SetCell(currentRow, 1,
+				tview.NewTableCell(now.Sub(c.started).Round(time.Second).String()).SetAlign(tview.AlignRight))
+			t.SetCell(currentRow, 2,
+				tview.NewTableCell(c.deadline.Sub(now).Round(time.Second).String()).SetAlign(tview.AlignRight))
+			t.SetCell(currentRow, 3, tview.NewTableCell("[grey]"+tview.Escape(formatDesc(c.params))))
+		}
+		if len(calls) == 0 {
+			t.SetCell(rowOffset, 0, tview.NewTableCell("[grey]no calls in flight"))
+		}
+	}
+	fill()
+
+	page := ui.Modal(t, 100, 20)
+
+	// refresh while shown
+	hidden := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-hidden:
+				return
+			case <-time.After(time.Second):
+			}
+			ui.app.QueueUpdateDraw(func() {
+				if ui.primitives["inflight"] != page {
+					close(hidden)
+					return
+				}
+				fill()
+			})
+		}
+	}()
+
+	t.SetSelectedFunc(func(row, column int) {
+		if row < rowOffset || row-rowOffset >= len(calls) {
+			return
+		}
+		c := calls[row-rowOffset]
+		if paymentMethods[c.method] {
+			ui.log.Warn(fmt.Sprintf("Payments can't be stopped, lightningd gives up on this %s within %s\n",
+				c.method, time.Until(c.deadline).Round(time.Second)))
+			return
+		}
+		c.cancel()
+		// there is no cancelling a command, lightningd sees it through
+		ui.log.Warn(fmt.Sprintf("Stopped waiting for %s after %s, lightningd carries on with it\n",
+			c.method, time.Since(c.started).Round(time.Second)))
+		fill()
+	})
+	t.SetDoneFunc(func(key tcell.Key) {
+		ui.DeletePage("inflight")
+		ui.FocusMenu()
+	})
+	return page
+}
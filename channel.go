@@ -4,10 +4,10 @@ type Channel struct {
 	shortChannelID string
 	state          string
 	active         bool
-	capacity       int64
-	localBalance   int64
-	remoteBalance  int64
-	commitFee      int64
+	capacity       Msat
+	localBalance   Msat
+	remoteBalance  Msat
+	commitFee      Msat
 	localNodeID    string
 	remoteNodeID   string
 	remoteAlias    string
@@ -17,10 +17,11 @@ type Channel struct {
 	remoteFeeRate  int64
 	lastForward    float64
 	opener         string
-	localFees      int64
-	remoteFees     int64
+	localFees      Msat
+	remoteFees     Msat
 	private        bool
 	peerConnected  bool
 	block          int64
 	age            int64
+	reliability    Reliability
 }
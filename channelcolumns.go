@@ -0,0 +1,596 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type ChannelColumn struct {
+	name   string
+	header string
+	align  int
+	cell   func(ui *UI, channel Channel) string
+	// total is nil for columns without a total
+	total func(channels []Channel) string
+	// compare orders two channels ascending, it is nil for columns that
+	// can't be sorted
+	compare func(c1, c2 Channel) int
+}
+
+// columns shown until the user picks their own
+var defaultChannelColumns = []string{
+	"inbound", "balance", "outbound", "local_base_fee", "local_fee_rate",
+	"remote_base_fee", "remote_fee_rate", "last_forward", "local_fees",
+	"remote_fees", "status", "age", "balance_trend", "reliability", "alias",
+}
+
+func compareInt64(a, b int64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+func compareString(a, b string) int {
+	return strings.Compare(strings.ToLower(a), strings.ToLower(b))
+}
+
+// balanceRatio is the share of the channel funds on our side.
+func balanceRatio(channel Channel) float64 {
+	spendable := channel.capacity - channel.commitFee
+	if spendable <= 0 {
+		return 0
+	}
+	return float64(channel.localBalance) / float64(spendable)
+}
+
+// earnedPPM is the fees earned by the channel per sat of capacity.
+func earnedPPM(channel Channel) int64 {
+	if channel.capacity == 0 {
+		return 0
+	}
+	return int64(channel.localFees * 1000000 / channel.capacity)
+}
+
+// idleDays is the number of days since the last forward, or since the
+// opening for channels that never forwarded.
+func idleDays(channel Channel) float64 {
+	if channel.lastForward == 0 {
+		return float64(channel.age) / blocksPerDay
+	}
+	return formatDaysSince(channel.lastForward)
+}
+
+func channelStatus(channel Channel) string {
+	state := formatChannelState(channel)
+	if idx := strings.Index(state, "]"); idx >= 0 {
+		state = state[idx+1:]
+	}
+	return state
+}
+
+func sumChannels(channels []Channel, value func(channel Channel) Msat) Msat {
+	total := Msat(0)
+	for _, channel := range channels {
+		total += value(channel)
+	}
+	return total
+}
+
+func formatChannelState(channel Channel) string {
+	var state string
+	switch channel.state {
+	case "CHANNELD_AWAITING_LOCKIN":
+		state = "[orange]opening"
+	case "CHANNELD_NORMAL":
+		if channel.peerConnected {
+			state = "[green]online"
+		} else {
+			state = "[grey]offline"
+		}
+	case "AWAITING UNILATERAL":
+		state = "[orange]awaiting unilateral"
+	case "CHANNELD_SHUTTING_DOWN", "CLOSINGD_SIGEXCHANGE", "CLOSINGD_COMPLETE":
+		state = "[lightgrey]closing"
+	case "ONCHAIN":
+		state = "[lightgrey]onchain"
+	case "CLOSED":
+		state = "[grey]closed"
+	}
+	return state
+}
+
+func formatChannelAlias(channel Channel) string {
+	var aliasColor string
+	if channel.peerConnected {
+		switch channel.opener {
+		case "local":
+			aliasColor = "[greenyellow]"
+		case "remote":
+			aliasColor = "[darkviolet]"
+		}
+	} else {
+		switch channel.opener {
+		case "local":
+			aliasColor = "[#9DB27C]"
+		case "remote":
+			aliasColor = "[#71577C]"
+		}
+	}
+	return aliasColor + channel.remoteAlias
+}
+
+func formatLastForward(channel Channel) string {
+	lastForward := formatDaysSince(channel.lastForward)
+	var lastForwardFormatted string
+	if lastForward > 0.0 {
+		if lastForward > 60 {
+			lastForwardFormatted = fmt.Sprintf("%s%.1f", "[red]", lastForward)
+		} else {
+			lastForwardFormatted = fmt.Sprintf("%s%.1f", "[white]", lastForward)
+		}
+	} else {
+		lastForwardFormatted = "never"
+	}
+	return lastForwardFormatted
+}
+
+var channelColumns = []ChannelColumn{
+	{"inbound", "\n[bold]inbound", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[red]" + c.remoteBalance.Format() },
+		func(channels []Channel) string {
+			return "[red]" + sumChannels(channels, func(c Channel) Msat { return c.remoteBalance }).Format()
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.remoteBalance), int64(c2.remoteBalance)) }},
+	{"balance", "\nbalance", tview.AlignCenter,
+		func(ui *UI, c Channel) string { return getBalance(c) }, nil,
+		func(c1, c2 Channel) int { return compareFloat(balanceRatio(c1), balanceRatio(c2)) }},
+	{"outbound", "\noutbound", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[green]" + c.localBalance.Format() },
+		func(channels []Channel) string {
+			return "[green]" + sumChannels(channels, func(c Channel) Msat { return c.localBalance }).Format()
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.localBalance), int64(c2.localBalance)) }},
+	{"local_base_fee", "local\nbase_fee\n(msat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[deepskyblue]" + formatSats(c.localBaseFee) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(c1.localBaseFee, c2.localBaseFee) }},
+	{"local_fee_rate", "local\nfee_rate\n(ppm)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[deepskyblue]" + formatSats(c.localFeeRate) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(c1.localFeeRate, c2.localFeeRate) }},
+	{"remote_base_fee", "remote\nbase_fee\n(msat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[lightyellow]" + formatSats(c.remoteBaseFee) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(c1.remoteBaseFee, c2.remoteBaseFee) }},
+	{"remote_fee_rate", "remote\nfee_rate\n(ppm)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[lightyellow]" + formatSats(c.remoteFeeRate) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(c1.remoteFeeRate, c2.remoteFeeRate) }},
+	{"last_forward", "last\nforward\n(days)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return formatLastForward(c) }, nil,
+		func(c1, c2 Channel) int { return compareFloat(idleDays(c1), idleDays(c2)) }},
+	{"local_fees", "local\nfees earned\n(sat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[deepskyblue]" + c.localFees.Format() },
+		func(channels []Channel) string {
+			return "[deepskyblue]" + sumChannels(channels, func(c Channel) Msat { return c.localFees }).Format()
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.localFees), int64(c2.localFees)) }},
+	{"remote_fees", "remote\nfees earned\n(sat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[lightyellow]" + c.remoteFees.Format() },
+		func(channels []Channel) string {
+			return "[lightyellow]" + sumChannels(channels, func(c Channel) Msat { return c.remoteFees }).Format()
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.remoteFees), int64(c2.remoteFees)) }},
+	{"status", "\nstatus", tview.AlignCenter,
+		func(ui *UI, c Channel) string { return formatChannelState(c) }, nil,
+		func(c1, c2 Channel) int { return compareString(channelStatus(c1), channelStatus(c2)) }},
+	{"age", "\nage\n(blocks)", tview.AlignCenter,
+		func(ui *UI, c Channel) string { return fmt.Sprintf("%d", c.age) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(c1.age, c2.age) }},
+	{"balance_trend", "balance\ntrend\n(7 days)", tview.AlignLeft,
+		func(ui *UI, c Channel) string {
+			return "[green]" + ui.metrics.Sparkline("channel."+c.shortChannelID+".local_balance", 7*24*time.Hour, 14)
+		}, nil, nil},
+	{"reliability", "\nreliability\n(%)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return formatReliability(c.reliability.score) }, nil,
+		func(c1, c2 Channel) int { return compareFloat(c1.reliability.score, c2.reliability.score) }},
+	{"alias", "\nalias", tview.AlignLeft,
+		func(ui *UI, c Channel) string { return formatChannelAlias(c) }, nil,
+		func(c1, c2 Channel) int { return compareString(c1.remoteAlias, c2.remoteAlias) }},
+	{"outbound_fiat", "\noutbound\n(fiat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[green]" + formatFiatAmount(c.localBalance, time.Now()) },
+		func(channels []Channel) string {
+			return "[green]" + formatFiatAmount(sumChannels(channels, func(c Channel) Msat { return c.localBalance }), time.Now())
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.localBalance), int64(c2.localBalance)) }},
+	{"capacity", "\ncapacity\n(sat)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return c.capacity.Format() },
+		func(channels []Channel) string {
+			return sumChannels(channels, func(c Channel) Msat { return c.capacity }).Format()
+		},
+		func(c1, c2 Channel) int { return compareInt64(int64(c1.capacity), int64(c2.capacity)) }},
+	{"earned_ppm", "earned\n(ppm of\ncapacity)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return "[deepskyblue]" + formatSats(earnedPPM(c)) }, nil,
+		func(c1, c2 Channel) int { return compareInt64(earnedPPM(c1), earnedPPM(c2)) }},
+	{"balance_skew", "balance\nskew\n(%)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return fmt.Sprintf("%.0f", balanceSkew(c)) }, nil,
+		func(c1, c2 Channel) int { return compareFloat(balanceSkew(c1), balanceSkew(c2)) }},
+	{"idle", "\nidle\n(days)", tview.AlignRight,
+		func(ui *UI, c Channel) string { return fmt.Sprintf("%.1f", idleDays(c)) }, nil,
+		func(c1, c2 Channel) int { return compareFloat(idleDays(c1), idleDays(c2)) }},
+	{"scid", "\nshort\nchannel id", tview.AlignLeft,
+		func(ui *UI, c Channel) string { return c.shortChannelID }, nil,
+		func(c1, c2 Channel) int { return compareSCID(c1.shortChannelID, c2.shortChannelID) }},
+}
+
+func findChannelColumn(name string) (ChannelColumn, bool) {
+	for _, column := range channelColumns {
+		if column.name == name {
+			return column, true
+		}
+	}
+	return ChannelColumn{}, false
+}
+
+// visibleChannelColumns returns the columns chosen by the user, in their
+// order, or all of them.
+func visibleChannelColumns(ui *UI) []ChannelColumn {
+	names := getSettings(ui).ChannelColumns
+	if len(names) == 0 {
+		names = defaultChannelColumns
+	}
+	var columns []ChannelColumn
+	for _, name := range names {
+		if column, exists := findChannelColumn(name); exists {
+			columns = append(columns, column)
+		}
+	}
+	return columns
+}
+
+type ChannelFilter struct {
+	search  string
+	status  string
+	opener  string
+	privacy string
+	minSkew int64
+	minIdle int64
+}
+
+var channelFilter = ChannelFilter{
+	status:  "all",
+	opener:  "all",
+	privacy: "all",
+}
+
+// balanceSkew is 0 for a perfectly balanced channel and 100 when all the
+// funds are on one side.
+func balanceSkew(channel Channel) float64 {
+	if channel.capacity == 0 {
+		return 0
+	}
+	return math.Abs(float64(channel.localBalance)/float64(channel.capacity)-0.5) * 200
+}
+
+func (f ChannelFilter) matches(channel Channel) bool {
+	if f.search != "" {
+		term := strings.ToLower(strings.TrimSpace(f.search))
+		if !strings.Contains(strings.ToLower(channel.remoteAlias), term) &&
+			!strings.Contains(channel.shortChannelID, term) &&
+			!strings.Contains(channel.remoteNodeID, term) {
+			return false
+		}
+	}
+	switch f.status {
+	case "online":
+		if !channel.peerConnected {
+			return false
+		}
+	case "offline":
+		if channel.peerConnected {
+			return false
+		}
+	}
+	if f.opener != "all" && channel.opener != f.opener {
+		return false
+	}
+	switch f.privacy {
+	case "private":
+		if !channel.private {
+			return false
+		}
+	case "public":
+		if channel.private {
+			return false
+		}
+	}
+	if f.minSkew > 0 && balanceSkew(channel) < float64(f.minSkew) {
+		return false
+	}
+	if f.minIdle > 0 && idleDays(channel) < float64(f.minIdle) {
+		return false
+	}
+	return true
+}
+
+func (f ChannelFilter) active() bool {
+	return f.search != "" || f.status != "all" || f.opener != "all" ||
+		f.privacy != "all" || f.minSkew > 0 || f.minIdle > 0
+}
+
+func filterChannels(channels []Channel) []Channel {
+	var results []Channel
+	for _, channel := range channels {
+		if channelFilter.matches(channel) {
+			results = append(results, channel)
+		}
+	}
+	return results
+}
+
+func (ui *UI) NewChannelFilterPage() tview.Primitive {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Filter channels ")
+	form.SetBorderColor(BorderColor)
+
+	dropDown := func(label string, options []string, current string) {
+		initial := 0
+		for idx, option := range options {
+			if option == current {
+				initial = idx
+			}
+		}
+		form.AddDropDown(label, options, initial, nil)
+	}
+	dropDown("Status", []string{"all", "online", "offline"}, channelFilter.status)
+	dropDown("Opener", []string{"all", "local", "remote"}, channelFilter.opener)
+	dropDown("Private", []string{"all", "private", "public"}, channelFilter.privacy)
+	form.AddInputField("Balance skew above (%)", strconv.FormatInt(channelFilter.minSkew, 10), 10, tview.InputFieldInteger, nil)
+	form.AddInputField("Idle for more than (days)", strconv.FormatInt(channelFilter.minIdle, 10), 10, tview.InputFieldInteger, nil)
+
+	apply := func() {
+		_, channelFilter.status = form.GetFormItemByLabel("Status").(*tview.DropDown).GetCurrentOption()
+		_, channelFilter.opener = form.GetFormItemByLabel("Opener").(*tview.DropDown).GetCurrentOption()
+		_, channelFilter.privacy = form.GetFormItemByLabel("Private").(*tview.DropDown).GetCurrentOption()
+		channelFilter.minSkew, _ = strconv.ParseInt(form.GetFormItemByLabel("Balance skew above (%)").(*tview.InputField).GetText(), 10, 64)
+		channelFilter.minIdle, _ = strconv.ParseInt(form.GetFormItemByLabel("Idle for more than (days)").(*tview.InputField).GetText(), 10, 64)
+		ui.DeletePage("channelFilter")
+		ui.ReloadPage("channels")
+	}
+	form.AddButton("Apply", apply)
+	form.AddButton("Clear", func() {
+		channelFilter = ChannelFilter{status: "all", opener: "all", privacy: "all"}
+		ui.DeletePage("channelFilter")
+		ui.ReloadPage("channels")
+	})
+	form.SetCancelFunc(func() {
+		ui.DeletePage("channelFilter")
+		ui.SetFocus("channels")
+	})
+
+	return ui.Modal(form, 50, 17)
+}
+
+// NewChannelColumnsPage lets the user show, hide and reorder the columns of
+// the channels table. The choice is saved in the settings.
+func (ui *UI) NewChannelColumnsPage() tview.Primitive {
+	type columnChoice struct {
+		column  ChannelColumn
+		visible bool
+	}
+	var choices []columnChoice
+	for _, column := range visibleChannelColumns(ui) {
+		choices = append(choices, columnChoice{column, true})
+	}
+	for _, column := range channelColumns {
+		shown := false
+		for _, choice := range choices {
+			if choice.column.name == column.name {
+				shown = true
+			}
+		}
+		if !shown {
+			choices = append(choices, columnChoice{column, false})
+		}
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetBorder(true)
+	list.SetBorderColor(BorderColor)
+	list.SetTitle(" Columns (space: show/hide, J/K: move, enter: save) ")
+
+	var draw func(selected int)
+	draw = func(selected int) {
+		list.Clear()
+		for _, choice := range choices {
+			mark := "[grey][ ] "
+			if choice.visible {
+				mark = "[green][x] "
+			}
+			list.AddItem(mark+"[white]"+strings.ReplaceAll(strings.TrimPrefix(choice.column.header, "\n"), "\n", " "), "", 0, nil)
+		}
+		list.SetCurrentItem(selected)
+	}
+	draw(0)
+
+	save := func() {
+		var names []string
+		for _, choice := range choices {
+			if choice.visible {
+				names = append(names, choice.column.name)
+			}
+		}
+		if len(names) == 0 {
+			ui.log.Warn("At least one column must be visible\n")
+			return
+		}
+		getSettings(ui).ChannelColumns = names
+		saveSettings(ui)
+		ui.DeletePage("channelColumns")
+		ui.ReloadPage("channels")
+	}
+
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		current := list.GetCurrentItem()
+		switch {
+		case event.Key() == tcell.KeyEnter:
+			save()
+			return nil
+		case event.Key() == tcell.KeyEscape:
+			ui.DeletePage("channelColumns")
+			ui.SetFocus("channels")
+			return nil
+		case event.Rune() == ' ':
+			choices[current].visible = !choices[current].visible
+			draw(current)
+			return nil
+		case event.Rune() == 'K' && current > 0:
+			choices[current-1], choices[current] = choices[current], choices[current-1]
+			draw(current - 1)
+			return nil
+		case event.Rune() == 'J' && current < len(choices)-1:
+			choices[current+1], choices[current] = choices[current], choices[current+1]
+			draw(current + 1)
+			return nil
+		case event.Rune() == 'j':
+			return tcell.NewEventKey(tcell.KeyDown, 0, tcell.ModNone)
+		case event.Rune() == 'k':
+			return tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone)
+		}
+		return event
+	})
+
+	return ui.Modal(list, 60, len(choices)+2)
+}
+
+type SortKey struct {
+	Column     string `json:"column"`
+	Descending bool   `json:"descending"`
+}
+
+var defaultChannelSort = []SortKey{{"balance", false}}
+
+func (s *Settings) channelSortKeys() []SortKey {
+	if len(s.ChannelSort) == 0 {
+		return defaultChannelSort
+	}
+	return s.ChannelSort
+}
+
+// compareSCID orders short channel ids by block, transaction and output.
+func compareSCID(a, b string) int {
+	pa := strings.Split(a, "x")
+	pb := strings.Split(b, "x")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, errA := strconv.ParseInt(pa[i], 10, 64)
+		nb, errB := strconv.ParseInt(pb[i], 10, 64)
+		if errA != nil || errB != nil {
+			return strings.Compare(a, b)
+		}
+		if c := compareInt64(na, nb); c != 0 {
+			return c
+		}
+	}
+	return compareInt64(int64(len(pa)), int64(len(pb)))
+}
+
+// sortChannels orders channels by keys, the first one being the primary key.
+// Channels equal on all keys are ordered by short channel id.
+func sortChannels(channels []Channel, keys []SortKey) []Channel {
+	sort.SliceStable(channels, func(i, j int) bool {
+		for _, key := range keys {
+			column, exists := findChannelColumn(key.Column)
+			if !exists || column.compare == nil {
+				continue
+			}
+			c := column.compare(channels[i], channels[j])
+			if key.Descending {
+				c = -c
+			}
+			if c != 0 {
+				return c < 0
+			}
+		}
+		return compareSCID(channels[i].shortChannelID, channels[j].shortChannelID) < 0
+	})
+	return channels
+}
+
+// toggleSortKey makes column the primary sort key, or reverses it when it
+// already is. With secondary set, the column is added after the current keys
+// instead.
+func toggleSortKey(keys []SortKey, column string, secondary bool) []SortKey {
+	for idx, key := range keys {
+		if key.Column != column {
+			continue
+		}
+		if idx == 0 || secondary {
+			updated := append([]SortKey{}, keys...)
+			updated[idx].Descending = !key.Descending
+			return updated
+		}
+		// promote to primary key
+		updated := []SortKey{key}
+		updated = append(updated, keys[:idx]...)
+		return append(updated, keys[idx+1:]...)
+	}
+	if secondary {
+		return append(append([]SortKey{}, keys...), SortKey{column, false})
+	}
+	return []SortKey{{column, false}}
+}
+
+// renderChannelHeaders writes the column headers in the first rows of t,
+// marking the sort keys and highlighting the column under the cursor.
+func renderChannelHeaders(t *Table, columns []ChannelColumn, keys []SortKey, cursor int) {
+	headerRows := 0
+	for _, column := range columns {
+		if lines := strings.Count(column.header, "\n") + 1; lines > headerRows {
+			headerRows = lines
+		}
+	}
+	for col, column := range columns {
+		lines := strings.Split(strings.ReplaceAll(column.header, "(sat)", "("+amountUnit+")"), "\n")
+		for idx, key := range keys {
+			if key.Column != column.name {
+				continue
+			}
+			arrow := "▲"
+			if key.Descending {
+				arrow = "▼"
+			}
+			if len(keys) > 1 {
+				arrow += strconv.Itoa(idx + 1)
+			}
+			lines[len(lines)-1] += " " + arrow
+		}
+		for row := 0; row < headerRows; row++ {
+			text := ""
+			if row < len(lines) {
+				text = lines[row]
+			}
+			cell := tview.NewTableCell(text).SetTextColor(tcell.ColorWhite).SetAlign(column.align)
+			if col == cursor {
+				cell.SetBackgroundColor(tcell.ColorDarkSlateGray)
+			}
+			t.SetCell(row, col, cell)
+		}
+	}
+}
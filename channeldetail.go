@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"time"
+)
+
+const chartWidth = 60
+const chartHeight = 6
+
+// feeChart draws the fee rate history of one side of the channel.
+func feeChart(history []FeeSnapshot) string {
+	if len(history) == 0 {
+		return "[grey]No fee history recorded yet\n"
+	}
+	var points []ChartPoint
+	for _, snapshot := range history {
+		points = append(points, ChartPoint{snapshot.Time, float64(snapshot.Rate)})
+	}
+	from := history[0].Time
+	to := time.Now().Unix()
+	chart := stepChart(points, from, to, chartWidth, chartHeight)
+	return chart + fmt.Sprintf("[grey]%10s%-30s%30s\n", "",
+		time.Unix(from, 0).Format("2006-01-02"), time.Unix(to, 0).Format("2006-01-02"))
+}
+
+func (ui *UI) NewChannelDetailPage(channel Channel, parent string) tview.Primitive {
+	tv := tview.NewTextView()
+	tv.SetBorder(true).SetBorderColor(BorderColor)
+	tv.SetTitle(" Channel " + channel.shortChannelID + " ")
+	tv.SetDynamicColors(true)
+	tv.SetScrollable(true)
+
+	ic := NewInfoColumn("[deepskyblue]", "[white]")
+	ic.AddRow("Alias", channel.remoteAlias)
+	ic.AddRow("Node id", channel.remoteNodeID)
+	ic.AddRow("Opener", channel.opener)
+	ic.AddRow("Capacity ("+amountUnit+")", channel.capacity.Format())
+	ic.AddRow("Inbound ("+amountUnit+")", "[red]"+channel.remoteBalance.Format())
+	ic.AddRow("Outbound ("+amountUnit+")", "[green]"+channel.localBalance.Format())
+	ic.AddRow("Commit fee ("+amountUnit+")", channel.commitFee.Format())
+	ic.AddRow("Local fee", fmt.Sprintf("%d msat + %d ppm", channel.localBaseFee, channel.localFeeRate))
+	ic.AddRow("Remote fee", fmt.Sprintf("%d msat + %d ppm", channel.remoteBaseFee, channel.remoteFeeRate))
+	ic.AddRow("Local fees earned ("+amountUnit+")", "[deepskyblue]"+channel.localFees.Format())
+	ic.AddRow("Remote fees earned ("+amountUnit+")", "[lightyellow]"+channel.remoteFees.Format())
+	ic.AddRow("Funding block", fmt.Sprintf("%d (%d blocks ago)", channel.block, channel.age))
+	ic.AddRow("Uptime 24h/7d/30d (%)", fmt.Sprintf("%s [white]/ %s [white]/ %s",
+		formatReliability(100*channel.reliability.uptime24h),
+		formatReliability(100*channel.reliability.uptime7d),
+		formatReliability(100*channel.reliability.uptime30d)))
+	ic.AddRow("Channel active 7d (%)", formatReliability(100*channel.reliability.active7d))
+	ic.AddRow("Forward success (%)", formatReliability(100*channel.reliability.forwardSuccess))
+	ic.AddRow("Reliability score", formatReliability(channel.reliability.score))
+	ic.Print(tv)
+
+	history := getFeeHistory(ui)
+	fmt.Fprint(tv, "\n[deepskyblue]Local fee rate history (ppm)\n")
+	fmt.Fprint(tv, feeChart(history.History(channel.shortChannelID, channel.localNodeID)))
+	fmt.Fprint(tv, "\n[deepskyblue]Remote fee rate history (ppm)\n")
+	fmt.Fprint(tv, feeChart(history.History(channel.shortChannelID, channel.remoteNodeID)))
+
+	tv.SetDoneFunc(func(key tcell.Key) {
+		ui.DeletePage("channelDetail")
+		ui.SetFocus(parent)
+	})
+
+	return ui.Modal(tv, 80, 45)
+}
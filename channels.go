@@ -6,123 +6,16 @@ import (
 	"github.com/rivo/tview"
 	"github.com/tidwall/gjson"
 	"math"
-	"regexp"
-	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
-type SortFunc func(channels []Channel) []Channel
-type SortFuncs map[string]SortFunc
-
-var sortFuncs = SortFuncs {
-	"Channel balance": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.localBalance)/float32(c1.capacity-c1.commitFee) < float32(c2.localBalance)/float32(c2.capacity-c2.commitFee)
-		})
-		return channels
-	},
-	"Inbound liquidity": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.remoteBalance) > float32(c2.remoteBalance)
-		})
-		return channels
-	},
-	"Outbound liquidity": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.localBalance) > float32(c2.localBalance)
-		})
-		return channels
-	},
-	"Local base fee": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.localBaseFee) > float32(c2.localBaseFee)
-		})
-		return channels
-	},
-	"Local fee rate": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.localFeeRate) > float32(c2.localFeeRate)
-		})
-		return channels
-	},
-	"Remote base fee": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.remoteBaseFee) > float32(c2.remoteBaseFee)
-		})
-		return channels
-	},
-	"Remote fee rate": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.remoteFeeRate) > float32(c2.remoteFeeRate)
-		})
-		return channels
-	},
-	"Last forward": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.lastForward) > float32(c2.lastForward)
-		})
-		return channels
-	},
-	"Local fees earned": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.localFees) > float32(c2.localFees)
-		})
-		return channels
-	},
-	"Remote fees earned": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return float32(c1.remoteFees) > float32(c2.remoteFees)
-		})
-		return channels
-	},
-	"Remote alias": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return strings.ToLower(c1.remoteAlias) < strings.ToLower(c2.remoteAlias)
-		})
-		return channels
-	},
-	"Channel age (youngest first)": func(channels []Channel) []Channel {
-		sort.Slice(channels, func(i, j int) bool {
-			c1 := channels[i]
-			c2 := channels[j]
-			return c1.block > c2.block
-		})
-		return channels
-	},
-
-}
-
-var selectedSortFunc = "Channel balance"
-
 func getBalance(channel Channel) string {
 	//fmt.Println("chan_id = ", channel.shortChannelID)
 	//fmt.Println("localBalance = ", channel.localBalance)
 	//fmt.Println("capacity = ", channel.capacity)
-	send := int(10 * channel.localBalance / (channel.capacity - int64(channel.commitFee)))
+	send := int(10 * channel.localBalance / (channel.capacity - channel.commitFee))
 	recv := 10 - send
 	bar := "[red]" + strings.Repeat(".", recv) +
 		"[white]|" +
@@ -139,26 +32,26 @@ func formatDaysSince(ts float64) float64 {
 	}
 
 }
-func channelsPage(ui *UI) *Table {
+// column of the channels table under the sort cursor
+var channelSortCursor = 0
+
+func channelsPage(ui *UI) tview.Primitive {
+	allChannels, err := getChannels(ui)
+	if err != nil {
+		return ui.NewErrorPage("channels", err)
+	}
+
 	t := NewTable()
 	t.SetTitle(" Channels ")
 	t.SetBorder(true)
 	t.SetBorderColor(BorderColor)
 	t.SetSelectable(true, false)
 
-	t.AddColumnHeader("\n[bold]inbound", tview.AlignRight)
-	t.AddColumnHeader("\nbalance", tview.AlignCenter)
-	t.AddColumnHeader("\noutbound", tview.AlignRight)
-	t.AddColumnHeader("local\nbase_fee\n(msat)", tview.AlignRight)
-	t.AddColumnHeader("local\nfee_rate\n(ppm)", tview.AlignRight)
-	t.AddColumnHeader("remote\nbase_fee\n(msat)", tview.AlignRight)
-	t.AddColumnHeader("remote\nfee_rate\n(ppm)", tview.AlignRight)
-	t.AddColumnHeader("last\nforward\n(days)", tview.AlignRight)
-	t.AddColumnHeader("local\nfees earned\n(sat)", tview.AlignRight)
-	t.AddColumnHeader("remote\nfees earned\n(estimate)", tview.AlignRight)
-	t.AddColumnHeader("\nstatus", tview.AlignCenter)
-	t.AddColumnHeader("\nage\n(blocks)", tview.AlignCenter)
-	t.AddColumnHeader("\nalias", tview.AlignLeft)
+	columns := visibleChannelColumns(ui)
+	if channelSortCursor >= len(columns) {
+		channelSortCursor = 0
+	}
+	renderChannelHeaders(t, columns, getSettings(ui).channelSortKeys(), channelSortCursor)
 	t.Separator(11)
 	rowOffset := t.GetRowCount()
 	t.Select(rowOffset, 0)
@@ -169,14 +62,51 @@ func channelsPage(ui *UI) *Table {
 		ui.FocusMenu()
 	})
 
-	channels := getChannels(ui)
+	channels := filterChannels(allChannels)
 
-	t.SetSelectedFunc(func(row, column int) {
-		ui.log.Info("Selected channel id: " + fmt.Sprintf("%s", channels[row - rowOffset].shortChannelID) + "\n")
-		ui.log.Info("Current commit fee: " + fmt.Sprintf("%d", channels[row - rowOffset].commitFee) + " sats\n")
-		ui.log.Info("Remote base fee: " + fmt.Sprintf("%d", channels[row - rowOffset].remoteBaseFee) + "\n")
-		ui.log.Info("Block: " + fmt.Sprintf("%d", channels[row - rowOffset].block) + "\n")
+	refresh := func() {
+		channels = filterChannels(allChannels)
+		fillChannelsTable(ui, t, rowOffset, columns, channels)
+		if channelFilter.active() {
+			t.SetTitle(fmt.Sprintf(" Channels (%d of %d) ", len(channels), len(allChannels)))
+		} else {
+			t.SetTitle(" Channels ")
+		}
+		t.Select(rowOffset, 0)
+	}
+
+	sortBy := func(secondary bool) {
+		column := columns[channelSortCursor]
+		if column.compare == nil {
+			ui.log.Warn("Channels can't be sorted by " + column.name + "\n")
+			return
+		}
+		settings := getSettings(ui)
+		settings.ChannelSort = toggleSortKey(settings.channelSortKeys(), column.name, secondary)
+		saveSettings(ui)
+		sortChannels(allChannels, settings.ChannelSort)
+		renderChannelHeaders(t, columns, settings.ChannelSort, channelSortCursor)
+		refresh()
+	}
 
+	selected := func() (Channel, bool) {
+		currentRow, _ := t.GetSelection()
+		if currentRow < rowOffset || currentRow - rowOffset >= len(channels) {
+			return Channel{}, false
+		}
+		return channels[currentRow - rowOffset], true
+	}
+
+	t.SetSelectedFunc(func(row, column int) {
+		channel, ok := selected()
+		if !ok {
+			return
+		}
+		if ui.HasPage("channelDetail") {
+			ui.DeletePage("channelDetail")
+		}
+		ui.AddPage("channelDetail", ui.NewChannelDetailPage(channel, "channels"), true, true)
+		ui.SetFocus("channelDetail")
 	})
 
 	// Do not allow to select the header
@@ -186,6 +116,29 @@ func channelsPage(ui *UI) *Table {
 		}
 	})
 
+	t.SetExport(ui, "channels", func() Export {
+		return channelsExport(channels)
+	})
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow)
+
+	search := tview.NewInputField()
+	search.SetLabel("/")
+	search.SetText(channelFilter.search)
+	search.SetChangedFunc(func(text string) {
+		channelFilter.search = text
+		refresh()
+	})
+	search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEscape {
+			search.SetText("")
+		}
+		if search.GetText() == "" {
+			flex.ResizeItem(search, 0, 0)
+		}
+		ui.app.SetFocus(t)
+	})
+
 	// Keyboard handler
 	t.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
 		switch event.Rune() {
@@ -193,24 +146,64 @@ func channelsPage(ui *UI) *Table {
 			if ui.HasPage("openChannel") {
 				ui.DeletePage("openChannel")
 			}
-			ui.AddPage("openChannel", ui.NewOpenChannelPage(), true, true)
-			ui.SetFocus("openChannel")
+			ui.LoadModal("openChannel", func(ui *UI) tview.Primitive {
+				return ui.NewOpenChannelPage("", "channels")
+			})
 		case 'f':
+			channel, ok := selected()
+			if !ok {
+				break
+			}
 			if ui.HasPage("channelFees") {
 				ui.DeletePage("channelFees")
 			}
-			currentRow, _ := t.GetSelection()
-			channel := channels[currentRow - rowOffset]
-			ui.AddPage("channelFees", ui.NewChannelFeesPage(channel), true, true)
+			ui.AddPage("channelFees", ui.NewChannelFeesPage(channel, "channels"), true, true)
 			ui.SetFocus("channelFees")
+		case 'c':
+			channel, ok := selected()
+			if !ok {
+				break
+			}
+			if ui.HasPage("closeChannel") {
+				ui.DeletePage("closeChannel")
+			}
+			ui.AddPage("closeChannel", ui.NewCloseChannelPage(channel, "channels"), true, true)
+			ui.SetFocus("closeChannel")
+		case '<', '>':
+			if event.Rune() == '<' && channelSortCursor > 0 {
+				channelSortCursor -= 1
+			}
+			if event.Rune() == '>' && channelSortCursor < len(columns) - 1 {
+				channelSortCursor += 1
+			}
+			renderChannelHeaders(t, columns, getSettings(ui).channelSortKeys(), channelSortCursor)
 		case 's':
-			if ui.HasPage("channelSort") {
-				ui.DeletePage("channelSort")
-			} else {
-				ui.AddPage("channelSort", ui.NewChannelSortPage(), true, true)
-				//ui.pages.SwitchToPage("channelSort")
-				ui.SetFocus("channelSort")
+			sortBy(false)
+		case 'S':
+			sortBy(true)
+		case 'x':
+			settings := getSettings(ui)
+			settings.ChannelSort = nil
+			saveSettings(ui)
+			sortChannels(allChannels, settings.channelSortKeys())
+			renderChannelHeaders(t, columns, settings.channelSortKeys(), channelSortCursor)
+			refresh()
+		case '/':
+			flex.ResizeItem(search, 1, 0)
+			ui.app.SetFocus(search)
+			return nil
+		case 'F':
+			if ui.HasPage("channelFilter") {
+				ui.DeletePage("channelFilter")
+			}
+			ui.AddPage("channelFilter", ui.NewChannelFilterPage(), true, true)
+			ui.SetFocus("channelFilter")
+		case 'v':
+			if ui.HasPage("channelColumns") {
+				ui.DeletePage("channelColumns")
 			}
+			ui.AddPage("channelColumns", ui.NewChannelColumnsPage(), true, true)
+			ui.SetFocus("channelColumns")
 		case 'h':
 			help := []string{
 			"j/k   - Scroll down/up              ",
@@ -219,7 +212,14 @@ func channelsPage(ui *UI) *Table {
 			"o     - Open new channel            ",
 			"c     - Close selected channel      ",
 			"f     - Set channel fees            ",
-			"s     - Sort channels               ",
+			"</>   - Select column header        ",
+			"s     - Sort by column, again to flip",
+			"S     - Add column as secondary sort",
+			"x     - Reset sorting               ",
+			"/     - Search alias, scid, node id ",
+			"F     - Filter channels             ",
+			"v     - Choose and reorder columns  ",
+			"E     - Export table to CSV/JSON    ",
 			"h     - Toggle help                 ",
 			"ESC   - Focus menu pane             ",
 			}
@@ -233,121 +233,46 @@ func channelsPage(ui *UI) *Table {
 		return event
 	})
 
-	totalInbound := int64(0)
-	totalOutbound := int64(0)
-	localFees := int64(0)
-	remoteFees := int64(0)
-
-	for row, channel := range channels {
-		var state string
-		switch channel.state {
-		case "CHANNELD_AWAITING_LOCKIN":
-			state = "[orange]opening"
-		case "CHANNELD_NORMAL":
-			if channel.peerConnected {
-				state = "[green]online"
-			} else {
-				state = "[grey]offline"
-			}
-		case "AWAITING UNILATERAL":
-			state = "[orange]awaiting unilateral"
-		case "CHANNELD_SHUTTING_DOWN", "CLOSINGD_SIGEXCHANGE", "CLOSINGD_COMPLETE":
-			state = "[lightgrey]closing"
-		case "ONCHAIN":
-			state = "[lightgrey]onchain"
-		case "CLOSED":
-			state = "[grey]closed"
-		}
+	searchHeight := 0
+	if channelFilter.search != "" {
+		searchHeight = 1
+	}
+	flex.AddItem(search, searchHeight, 0, false)
+	flex.AddItem(t, 0, 1, true)
 
-		var aliasColor string
-		if channel.peerConnected {
-			switch channel.opener {
-			case "local":
-				aliasColor = "[greenyellow]"
-			case "remote":
-				aliasColor = "[darkviolet]"
-			}
-		} else {
-			switch channel.opener {
-			case "local":
-				aliasColor = "[#9DB27C]"
-			case "remote":
-				aliasColor = "[#71577C]"
-			}
-		}
-		lastForward := formatDaysSince(channel.lastForward)
-		var lastForwardFormatted string
-		if lastForward > 0.0 {
-			if lastForward > 60 {
-				lastForwardFormatted = fmt.Sprintf("%s%.1f", "[red]", lastForward)
-			} else {
-				lastForwardFormatted = fmt.Sprintf("%s%.1f", "[white]", lastForward)
-			}
-		} else {
-			lastForwardFormatted = "never"
-		}
+	refresh()
 
-		currentRow := row + rowOffset
-		t.SetCell(currentRow, 0,
-			tview.NewTableCell("[red]"+formatSats(channel.remoteBalance)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 1,
-			tview.NewTableCell(getBalance(channel)).SetAlign(tview.AlignCenter))
-		t.SetCell(currentRow, 2,
-			tview.NewTableCell("[green]"+formatSats(channel.localBalance)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 3,
-			tview.NewTableCell("[deepskyblue]"+formatSats(channel.localBaseFee)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 4,
-			tview.NewTableCell("[deepskyblue]"+formatSats(channel.localFeeRate)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 5,
-			tview.NewTableCell("[lightyellow]"+formatSats(channel.remoteBaseFee)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 6,
-			tview.NewTableCell("[lightyellow]"+formatSats(channel.remoteFeeRate)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 7,
-			tview.NewTableCell(lastForwardFormatted).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 8,
-			tview.NewTableCell("[deepskyblue]" + formatSats(channel.localFees)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 9,
-			tview.NewTableCell("[lightyellow]" + formatSats(channel.remoteFees)).SetAlign(tview.AlignRight))
-		t.SetCell(currentRow, 10,
-			tview.NewTableCell(state).SetAlign(tview.AlignCenter))
-		t.SetCell(currentRow, 11,
-			tview.NewTableCell(fmt.Sprintf("%d", channel.age)).SetAlign(tview.AlignCenter))
-		t.SetCell(currentRow, 12,
-			tview.NewTableCell(aliasColor + channel.remoteAlias))
-
-		t.Separator(11)
-
-		// totals
-		totalInbound += channel.remoteBalance
-		totalOutbound += channel.localBalance
-		localFees += channel.localFees
-		remoteFees += channel.remoteFees
+	return flex
+}
 
+// fillChannelsTable renders channels below the header, followed by the totals
+// of the columns that have one.
+func fillChannelsTable(ui *UI, t *Table, rowOffset int, columns []ChannelColumn, channels []Channel) {
+	for t.GetRowCount() > rowOffset {
+		t.RemoveRow(t.GetRowCount() - 1)
+	}
 
+	for row, channel := range channels {
+		currentRow := row + rowOffset
+		for col, column := range columns {
+			t.SetCell(currentRow, col,
+				tview.NewTableCell(column.cell(ui, channel)).SetAlign(column.align))
+		}
 	}
-	currentRow := t.GetRowCount()
+	t.Separator(11)
 
-	// Total inbound
-	t.SetCell(currentRow, 0,
-		tview.NewTableCell("[red]" + formatSats(totalInbound)).SetAlign(tview.AlignRight))
-	// Total outbound
-	t.SetCell(currentRow, 2,
-		tview.NewTableCell("[green]" + formatSats(totalOutbound)).SetAlign(tview.AlignRight))
-	// Total local fees
-	t.SetCell(currentRow, 8,
-		tview.NewTableCell("[deepskyblue]" + formatSats(localFees)).SetAlign(tview.AlignRight))
-	// Total remote fees
-	t.SetCell(currentRow, 9,
-		tview.NewTableCell("[lightyellow]" + formatSats(remoteFees)).SetAlign(tview.AlignRight))
-
-	return t
+	currentRow := t.GetRowCount()
+	for col, column := range columns {
+		if column.total != nil {
+			t.SetCell(currentRow, col,
+				tview.NewTableCell(column.total(channels)).SetAlign(column.align))
+		}
+	}
 }
-func getChannels(ui *UI) []Channel {
-	client := NewClient(ui)
-
-	getInfo, err := client.Call("getinfo")
+func getChannels(ui *UI) ([]Channel, error) {
+	getInfo, err := getInfo(ui)
 	if err != nil {
-		ui.log.Warn("error: " + err.Error() + "\n")
+		return nil, err
 	}
 
 	localNode := Node{
@@ -355,27 +280,33 @@ func getChannels(ui *UI) []Channel {
 		getInfo.Get("alias").String(),
 		getInfo.Get("color").String(),
 		getInfo.Get("blockheight").Int(),
+		"",
+		nil,
 		nil,
 	}
 
-	ui.log.Info("listpeers ")
+	var channels []Channel
 
-	peers, err := client.CallNamed("listpeers")
+	settled, err := getForwards(ui, map[string]interface{} {
+		"status": "settled",
+	})
 	if err != nil {
-		ui.log.Warn(" error: " + err.Error() + "\n")
+		return nil, err
 	}
+	forwards := settled.Get("forwards.#.{received_time,resolved_time,in_channel,out_channel,in_msat,in_msatoshi,fee_msat,fee}").Array()
 
-	ui.log.Ok("OK\n")
-
-	var channels []Channel
-
-	forwards := getForwards(ui, map[string]interface{} {
-		"status": "settled",
-	}).Get("forwards.#.{resolved_time,in_channel,out_channel,in_msatoshi,fee}").Array()
+	history := getFeeHistory(ui)
+	settledForwards, failedForwards, err := getForwardOutcomes(ui)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, peer := range peers.Get("peers").Array() {
-		channel := peer.Get("channels.0")
-		peerConnected := peer.Get("connected").Bool()
+	peerChannels, err := listPeerChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range peerChannels {
+		peerConnected := channel.peerConnected
 		state := channel.Get("state").String()
 		shortChannelID := channel.Get("short_channel_id").String()
 
@@ -393,17 +324,15 @@ func getChannels(ui *UI) []Channel {
 				age = localNode.blockheight - block
 			}
 		}
-		capacity := channel.Get("msatoshi_total").Int() / 1000
-		localBalance := channel.Get("msatoshi_to_us").Int() / 1000
-		lastTxFee, err := Mstoi(channel.Get("last_tx_fee").String())
-		if err != nil {
-			lastTxFee = 0
-		} else {
-			lastTxFee = lastTxFee / 1000
-		}
+		capacity := msatField(channel.Result, "total_msat", "msatoshi_total")
+		localBalance := msatField(channel.Result, "to_us_msat", "msatoshi_to_us")
+		lastTxFee := msatField(channel.Result, "last_tx_fee_msat", "last_tx_fee")
 		private := channel.Get("private").Bool()
 
-		chanInfo := getChannel(ui, shortChannelID)
+		chanInfo, err := getChannel(ui, shortChannelID)
+		if err != nil {
+			return nil, err
+		}
 		chanLen := chanInfo.Get("channels.#").Uint()
 
 		var localFee Fee
@@ -412,15 +341,9 @@ func getChannels(ui *UI) []Channel {
 		var node2Fee Fee
 
 		if chanLen > 0 {
-			node1Fee = Fee{
-				chanInfo.Get("channels.0.base_fee_millisatoshi").Int(),
-				chanInfo.Get("channels.0.fee_per_millionth").Int(),
-			}
+			node1Fee = gossipFee(chanInfo.Get("channels.0"))
 			if chanLen > 1 {
-				node2Fee = Fee{
-					chanInfo.Get("channels.1.base_fee_millisatoshi").Int(),
-					chanInfo.Get("channels.1.fee_per_millionth").Int(),
-				}
+				node2Fee = gossipFee(chanInfo.Get("channels.1"))
 				if localNode.id != chanInfo.Get("channels.0.source").String() {
 					remoteFee = node1Fee
 					localFee = node2Fee
@@ -438,7 +361,12 @@ func getChannels(ui *UI) []Channel {
 			}
 
 		}
-		remoteNodeID := peer.Get("id").String()
+		if fee, ok := channelLocalFee(channel.Result); ok {
+			localFee = fee
+		}
+		// keep track of the fees charged on both sides of the channel
+		history.RecordGossip(ui, chanInfo)
+		remoteNodeID := channel.peerID
 		remoteNode := listNode(ui, remoteNodeID)
 
 		var remoteAlias string
@@ -450,26 +378,38 @@ func getChannels(ui *UI) []Channel {
 		}
 
 		lastForward := 0.0
-		localFees := int64(0)
-		remoteFees := int64(0)
+		localFees := Msat(0)
+		remoteFees := Msat(0)
 
 		for _, forward := range forwards {
 			inChan := forward.Get("in_channel").String()
 			outChan := forward.Get("out_channel").String()
-			amountIn := forward.Get("in_msatoshi").Int() / 1000
+			amountIn := msatField(forward, "in_msat", "in_msatoshi")
 			// last forward
 			if shortChannelID == inChan || shortChannelID == outChan {
 				lastForward = math.Max(forward.Get("resolved_time").Float(), lastForward)
 			}
 			// local fees earned
 			if shortChannelID == outChan {
-				localFees += forward.Get("fee").Int() / 1000
+				localFees += msatValue(forward, "fee")
 			}
-			// remote fees (estimate if base/rate fee changed)
+			// remote fees, using the fee in effect when the forward was received
 			if shortChannelID == inChan {
-				remoteFees += (remoteFee.base + remoteFee.rate * amountIn / 1000) / 1000
+				fee, known := history.FeeAt(shortChannelID, remoteNodeID, forward.Get("received_time").Int())
+				if !known {
+					fee = remoteFee
+				}
+				remoteFees += Msat(fee.base) + Msat(fee.rate)*amountIn/1000000
 			}
 		}
+		series := "channel." + shortChannelID
+		ui.recordMetric(series + ".local_balance", localBalance.SatFloat())
+		ui.recordMetric(series + ".remote_balance", (capacity - localBalance).SatFloat())
+		ui.recordMetric(series + ".local_fee_rate", float64(localFee.rate))
+		ui.recordMetric(series + ".remote_fee_rate", float64(remoteFee.rate))
+		ui.recordMetric(series + ".local_fees", localFees.SatFloat())
+		ui.recordMetric("peer." + remoteNodeID + ".connected", boolMetric(peerConnected))
+
 		channels = append(channels, Channel{
 			state:     state,
 			shortChannelID: channel.Get("short_channel_id").String(),
@@ -493,100 +433,17 @@ func getChannels(ui *UI) []Channel {
 			peerConnected:  peerConnected,
 			block:          block,
 			age:            age,
+			reliability:    computeReliability(ui, shortChannelID, remoteNodeID, settledForwards[shortChannelID], failedForwards[shortChannelID]),
 		})
 
 	}
 
-	return sortFuncs[selectedSortFunc](channels)
+	return sortChannels(channels, getSettings(ui).channelSortKeys()), nil
 
 }
 
-func (ui *UI) NewOpenChannelPage() tview.Primitive {
-	form := tview.NewForm()
-	form.SetBorder(true)
-	form.SetTitle(" Open new channel ")
-	form.SetBorderColor(BorderColor)
-	form.AddInputField("Node Alias (ID)", "", 110, nil, nil)
-
-	funds := listFunds(ui, false)
-	var availableFunds int64
-
-	for _, utxo := range funds.Get("outputs").Array() {
-		if utxo.Get("status").String() == "confirmed" && !utxo.Get("reserved").Bool() {
-			availableFunds += utxo.Get("value").Int()
-		}
-	}
-	form.AddInputField("Available funds (sats)", fmt.Sprintf("%d", availableFunds), 110, func(text string, lastChar rune) bool { return false}, nil)
-
-
-	form.AddInputField("Channel size (sats)", "", 110, tview.InputFieldInteger, nil)
-	form.AddCheckbox("Announce", true, nil)
-	options := []string{
-		"slow",
-		"normal",
-		"urgent",
-	}
-	form.AddDropDown("Feerate", options, 1, nil)
-
-	searchAlias := form.GetFormItemByLabel("Node Alias (ID)").(*tview.InputField)
-	searchAlias.SetAutocompleteFunc(func(currentText string) (entries []string) {
-
-		if len(currentText) < 2 {
-			return
-		}
-		nodes := listNodesByAliasOrID(ui, currentText)
-		if len(nodes) > 0 {
-			for _, node := range nodes {
-				entries = append(entries, fmt.Sprintf("%s (%s)", node.alias, node.id))
-			}
-			if len(entries) == 0 {
-				entries = nil
-			}
-		}
-		return
-	})
-
-
-	form.AddButton("Open channel", func() {
-		nodeAliasField := form.GetFormItemByLabel("Node Alias (ID)").(*tview.InputField)
-		nodeAlias := nodeAliasField.GetText()
-
-		re := regexp.MustCompile(`\(([a-z0-9]+)\)$`)
-		matches := re.FindStringSubmatch(nodeAlias)
-		var nodeID string
-		if len(matches) == 2 {
-			nodeID = matches[1]
-			ui.log.Info("Match: " + matches[1] + "\n")
-		} else {
-			ui.log.Warn(fmt.Sprintf("Could not find node %s\n", nodeAlias))
-		}
-
-		channelSizeField := form.GetFormItemByLabel("Channel size (sats)").(*tview.InputField)
-		channelSize, err := strconv.Atoi(channelSizeField.GetText())
-		if err != nil {
-			ui.log.Warn(fmt.Sprintf("Incorrect channel size: %s", err.Error()))
-		}
-
-		feerateField := form.GetFormItemByLabel("Feerate").(*tview.DropDown)
-		_, feerate := feerateField.GetCurrentOption()
-
-		announceField := form.GetFormItemByLabel("Announce").(*tview.Checkbox)
-		announce := announceField.IsChecked()
-		ui.log.Info(fmt.Sprintf("Opening channel with %s, size: %d sats, feerate: %s, announce: %t\n", nodeID, channelSize, feerate, announce))
-		response := fundChannel(ui, nodeID, channelSize, feerate, announce)
-
-		ui.log.Info(response.String())
-		ui.pages.HidePage("openChannel")
-		ui.SetFocus("channels")
-	})
-	form.AddButton("Cancel", func() {
-		ui.pages.HidePage("openChannel")
-		ui.SetFocus("channels")
-	})
-
-	return ui.Modal(form, 120, 16)
-}
-func (ui *UI) NewChannelFeesPage(channel Channel) tview.Primitive {
+// NewChannelFeesPage sets the fees of channel and reloads the parent page.
+func (ui *UI) NewChannelFeesPage(channel Channel, parent string) tview.Primitive {
 	form := tview.NewForm()
 	form.SetBorder(true)
 	form.SetTitle(" Set channel fees ")
@@ -609,81 +466,69 @@ func (ui *UI) NewChannelFeesPage(channel Channel) tview.Primitive {
 			ui.log.Warn("Incorrect fee rate: " + err.Error() + "\n")
 		}
 
-		var results gjson.Result
+		id := channel.shortChannelID
 		if allChannelsField.IsChecked() {
 			// set for all channels
-			results = setChannelFee(ui, "all", baseFee, feeRate)
-		} else {
-			results = setChannelFee(ui, channel.shortChannelID, baseFee, feeRate)
+			id = "all"
+		}
+		results, err := setChannelFee(ui, id, baseFee, feeRate)
+		if err != nil {
+			ui.log.Warn(fmt.Sprintf("Error when setting fees: %s\n", err))
+			return
 		}
 
-		// If the response contains code field it means something went wrong
-		nodeID := results.Get("channels.0.peer_id").String()
-		if nodeID != "" {
-			node := listNode(ui, nodeID)
+		for _, updated := range results.Get("channels").Array() {
+			node := listNode(ui, updated.Get("peer_id").String())
 			ui.log.Info(fmt.Sprintf("Channel with %s: ", node.alias))
 			ui.log.Ok(fmt.Sprintf("Base fee: %d, Fee rate: %d\n", baseFee, feeRate))
-			ui.pages.HidePage("channelFees")
-			ui.AddPage("channels", channelsPage(ui), true, true)
-			ui.pages.SwitchToPage("channels")
-			ui.SetFocus("channels")
-		} else {
-			code := results.Get("code").Int()
-			msg := results.Get("message").String()
-			ui.log.Warn(fmt.Sprintf("Error when setting fees: (%d) %s\n", code, msg))
 		}
+		ui.pages.HidePage("channelFees")
+		ui.ReloadPage(parent)
 
 	})
 
 	form.AddButton("Cancel", func() {
 		ui.pages.HidePage("channelFees")
-		ui.SetFocus("channels")
+		ui.SetFocus(parent)
 	})
 
 	return ui.Modal(form, 38, 11)
 }
-func (ui *UI) NewChannelSortPage() tview.Primitive {
 
+// NewCloseChannelPage closes channel and reloads the parent page.
+func (ui *UI) NewCloseChannelPage(channel Channel, parent string) tview.Primitive {
 	form := tview.NewForm()
 	form.SetBorder(true)
-	form.SetTitle(" Sort channels by ")
+	form.SetTitle(" Close channel with " + channel.remoteAlias + " ")
 	form.SetBorderColor(BorderColor)
-	options := []string{
-		"Channel balance",
-		"Inbound liquidity",
-		"Outbound liquidity",
-		"Local base fee",
-		"Local fee rate",
-		"Remote base fee",
-		"Remote fee rate",
-		"Last forward",
-		"Local fees earned",
-		"Remote fees earned",
-		"Remote alias",
-		"Channel age (youngest first)",
-	}
-
-	initialOption := 0
-	for idx, option := range options {
-		if option == selectedSortFunc {
-			initialOption = idx
-			break
+	form.AddInputField("Channel", channel.shortChannelID, 20, func(text string, lastChar rune) bool { return false }, nil)
+	form.AddInputField("Unilateral after (s)", "172800", 20, tview.InputFieldInteger, nil)
+	form.AddButton("Close", func() {
+		timeoutField := form.GetFormItemByLabel("Unilateral after (s)").(*tview.InputField)
+		timeout, err := strconv.Atoi(timeoutField.GetText())
+		if err != nil {
+			ui.log.Warn("Incorrect timeout: " + err.Error() + "\n")
+			return
 		}
-	}
 
-	form.AddDropDown("Order channels by ", options, initialOption, func(option string, optionIdx int) {
-		if option != selectedSortFunc {
-			selectedSortFunc = option
-			ui.DeletePage("channelSort")
-			ui.AddPage("channels", channelsPage(ui), true, true)
-			ui.pages.SwitchToPage("channels")
-			ui.SetFocus("channels")
-		}
+		ui.log.Info(fmt.Sprintf("Closing channel %s with %s\n", channel.shortChannelID, channel.remoteAlias))
+		ui.pages.HidePage("closeChannel")
+		ui.SetFocus(parent)
+		closeChannel(ui, channel.shortChannelID, timeout, func(results gjson.Result, err error) {
+			if err != nil {
+				ui.log.Warn(fmt.Sprintf("Error when closing channel: %s\n", err))
+				return
+			}
+			ui.log.Ok(fmt.Sprintf("Channel closed (%s): %s\n", results.Get("type").String(), results.Get("txid").String()))
+			if front, _ := ui.pages.GetFrontPage(); front == parent {
+				ui.ReloadPage(parent)
+			}
+		})
 	})
-
-	form.SetCancelFunc(func() {
-		ui.pages.HidePage("channelSort")
-		ui.SetFocus("channels")
+	form.AddButton("Cancel", func() {
+		ui.pages.HidePage("closeChannel")
+		ui.SetFocus(parent)
 	})
-	return  ui.Modal(form, 40, 10)
-}
\ No newline at end of file
+
+	return ui.Modal(form, 50, 9)
+}
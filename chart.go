@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+type ChartPoint struct {
+	time  int64
+	value float64
+}
+
+// stepChart draws the points as a filled step chart of width x height
+// characters covering from..to (unix seconds), with the value scale on the
+// left. A point's value holds until the next point.
+func stepChart(points []ChartPoint, from, to int64, width, height int) string {
+	if len(points) == 0 || width < 2 || height < 1 || to <= from {
+		return "[grey]no data\n"
+	}
+
+	columns := make([]float64, width)
+	min := points[0].value
+	max := points[0].value
+	for col := 0; col < width; col++ {
+		t := from + (to-from)*int64(col)/int64(width-1)
+		value := points[0].value
+		for _, point := range points {
+			if point.time > t {
+				break
+			}
+			value = point.value
+		}
+		columns[col] = value
+		if value < min {
+			min = value
+		}
+		if value > max {
+			max = value
+		}
+	}
+	// always show the bottom row for the smallest value
+	if min > 0 {
+		min = 0
+	}
+
+	var sb strings.Builder
+	for row := height; row >= 1; row-- {
+		threshold := min + (max-min)*float64(row-1)/float64(height)
+		switch row {
+		case height:
+			fmt.Fprintf(&sb, "[white]%8.0f ┤", max)
+		case 1:
+			fmt.Fprintf(&sb, "[white]%8.0f ┤", min)
+		default:
+			fmt.Fprintf(&sb, "[white]%8s │", "")
+		}
+		sb.WriteString("[yellow]")
+		for _, value := range columns {
+			if value > threshold || (value == max && max == min) {
+				sb.WriteString("█")
+			} else {
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\n")
+	}
+	fmt.Fprintf(&sb, "[white]%8s └%s\n", "", strings.Repeat("─", width))
+	return sb.String()
+}
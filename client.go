@@ -1,11 +1,14 @@
 package main
 
 import (
+	"context"
+	"encoding/hex"
 	"fmt"
 	"github.com/fiatjaf/lightningd-gjson-rpc"
 	"github.com/tidwall/gjson"
 	"os"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -15,11 +18,23 @@ type LnClient struct {
 }
 
 var ln *LnClient
+var lnLock sync.Mutex
 var NodeCache = make(map[string]Node)
 var lastCacheLookup time.Time
+// nodeCacheLock guards NodeCache, pages look nodes up while loading in the
+// background.
+var nodeCacheLock sync.Mutex
+var NodeStatsCache = make(map[string]NodeStats)
+var lastStatsLookup time.Time
+var nodeStatsLock sync.Mutex
 const cacheFor = time.Second * 60
 
+// NewClient returns the client shared by the UI and the background pollers,
+// creating it the first time.
 func NewClient(ui *UI) *LnClient {
+	lnLock.Lock()
+	defer lnLock.Unlock()
+
 	if ln != nil {
 		return ln
 	} else {
@@ -28,9 +43,9 @@ func NewClient(ui *UI) *LnClient {
 			ui.log.Warn("RPC socket " + ui.rpcPath + " does't seem to exist.\n")
 			ui.log.Info("You can pass the path to the socket using --rpc=/path/to/lightning-rpc\n")
 		}
+		// calls set their own timeout, see callTimeouts
 		client := &lightning.Client{
-			Path:        ui.rpcPath,
-			CallTimeout: 60 * time.Second, // optional, defaults to 5 seconds
+			Path: ui.rpcPath,
 		}
 		ln = &LnClient{
 			client,
@@ -40,78 +55,152 @@ func NewClient(ui *UI) *LnClient {
 	}
 }
 
-func call(ui *UI, method string, params ...interface{}) gjson.Result {
-	client := NewClient(ui)
+// call calls method, returning an *RPCError when lightningd replies with an
+// error and a *ConnectionError when it can't be reached.
+func call(ui *UI, method string, params ...interface{}) (gjson.Result, error) {
+
+	return callContext(context.Background(), ui, method, params...)
+
+}
 
+// callContext calls method until ctx is done, or the timeout of the method
+// when ctx has no deadline. Cancelling only stops waiting, lightningd carries
+// on with the command.
+func callContext(ctx context.Context, ui *UI, method string, params ...interface{}) (gjson.Result, error) {
 	ui.log.Info(method + " ")
 
 	start := time.Now()
 
-	results, err := client.Call(method, params...)
-
+	results, err := callRPC(ctx, ui, method, params...)
 	if err != nil {
-		ui.log.Warn("error: " + err.Error())
+		ui.log.Warn("error: " + err.Error() + "\n")
+		return results, err
 	}
 
 	finish := time.Now()
 
 	ui.log.Ok(fmt.Sprintf("[%dms]\n", (finish.Sub(start)).Milliseconds()))
 
-	return results
+	return results, nil
+}
+
+// callSilently is call for the background pollers, which would fill the log.
+// Their calls are listed in flight all the same.
+func callSilently(ui *UI, method string, params ...interface{}) (gjson.Result, error) {
+	return callRPC(context.Background(), ui, method, params...)
+}
+
+// callRPC calls method, listed in flight until it's over, and reports losing
+// the connection to lightningd.
+func callRPC(ctx context.Context, ui *UI, method string, params ...interface{}) (gjson.Result, error) {
+	client := NewClient(ui)
+
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, callTimeout(method))
+		defer cancel()
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	deadline, _ := ctx.Deadline()
+	defer startCall(method, params, deadline, cancel)()
+
+	var results gjson.Result
+	var err error
+	if isConnectionLost() {
+		// reconnect tells when lightningd is back
+		err = &ConnectionError{ui.rpcPath, errConnectionLost}
+	} else {
+		results, err = callUntilDone(ctx, client, method, params...)
+		err = rpcError(ui, method, err)
+	}
+	if isConnectionError(err) {
+		ui.ConnectionLost()
+	}
+	return results, err
+}
+
+type callResult struct {
+	results gjson.Result
+	err     error
+}
+
+// callUntilDone returns the reply to method, or the reason ctx is done first.
+func callUntilDone(ctx context.Context, client *LnClient, method string, params ...interface{}) (gjson.Result, error) {
+	deadline, _ := ctx.Deadline()
+	done := make(chan callResult, 1)
+	go func() {
+		results, err := client.CallWithCustomTimeout(time.Until(deadline), method, params...)
+		done <- callResult{results, err}
+	}()
+	select {
+	case r := <-done:
+		return r.results, r.err
+	case <-ctx.Done():
+		return gjson.Result{}, ctx.Err()
+	}
 }
 
-func getInfo(ui *UI) gjson.Result {
+func getInfo(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "getinfo")
 
 }
-func getConfig(ui *UI) gjson.Result {
+func getConfig(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "listconfigs")
 
 }
-func getFeerates(ui *UI) gjson.Result {
+func getFeerates(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "feerates", "perkb")
 
 }
 
-func getNewAddr(ui *UI) gjson.Result {
+func getNewAddr(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "newaddr")
 
 }
-func getInvoices(ui *UI) gjson.Result {
+func getInvoices(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "listinvoices")
 
 }
-func getInvoice(ui *UI, params map[string]interface{}) gjson.Result {
+func getInvoice(ui *UI, params map[string]interface{}) (gjson.Result, error) {
 
-	invoice := call(ui, "invoice", params)
+	invoice, err := call(ui, "invoice", params)
+	if err != nil {
+		return invoice, err
+	}
 
 	ui.log.Ok("OK\n")
 	ui.log.Info("bolt11: [white]" + invoice.Get("bolt11").String() + "\n")
 	ui.log.Info("payment_hash: [white]" + invoice.Get("payment_hash").String() + "\n")
-	return invoice
+	return invoice, nil
 
 }
 
 func wrapNode(results gjson.Result) Node {
-	leaseFeeBaseMsat, err := Mstoi(results.Get("option_will_fund.lease_fee_base_msat").String())
+	leaseFeeBaseMsat, err := MsatFromResult(results.Get("option_will_fund.lease_fee_base_msat"))
 	skipOptionWillFund := false
 	if err != nil {
 		skipOptionWillFund = true
 	}
-	channelFeeMaxBaseMsat, err := Mstoi(results.Get("option_will_fund.channel_fee_max_base_msat").String())
+	channelFeeMaxBaseMsat, err := MsatFromResult(results.Get("option_will_fund.channel_fee_max_base_msat"))
 	if err != nil {
 		skipOptionWillFund = true
 	}
 
 	node := Node{
-		id:    results.Get("nodeid").String(),
-		alias: results.Get("alias").String(),
-		color: results.Get("color").String(),
+		id:       results.Get("nodeid").String(),
+		alias:    results.Get("alias").String(),
+		color:    results.Get("color").String(),
+		features: results.Get("features").String(),
+	}
+	for _, address := range results.Get("addresses").Array() {
+		node.addresses = append(node.addresses,
+			fmt.Sprintf("%s:%d", address.Get("address").String(), address.Get("port").Int()))
 	}
 	if !skipOptionWillFund {
 		leaseFeeBasis := results.Get("option_will_fund.lease_fee_basis").Int()
@@ -131,33 +220,50 @@ func wrapNode(results gjson.Result) Node {
 	return node
 }
 
+// listNode returns the node id from gossip. Nodes can't always be looked up,
+// callers fall back to the id when the alias is empty.
 func listNode(ui *UI, id string) Node {
 
+	nodeCacheLock.Lock()
 	n, exists := NodeCache[id]
+	nodeCacheLock.Unlock()
 	if exists {
 		return n
 	}
 
-	results := call(ui, "listnodes", id)
+	results, err := call(ui, "listnodes", id)
+	if err != nil {
+		return Node{id: id}
+	}
 
 	node := wrapNode(results.Get("nodes.0"))
 
+	nodeCacheLock.Lock()
 	NodeCache[id] = node
-	return NodeCache[id]
+	nodeCacheLock.Unlock()
+	return node
 
 }
 
-func listNodes(ui *UI) (results []Node) {
+func listNodes(ui *UI) (results []Node, err error) {
 
 	// cache
+	nodeCacheLock.Lock()
 	if lastCacheLookup.After(time.Now().Add(- cacheFor)) {
 		for _, node := range NodeCache {
 			results = append(results, node)
 		}
-		return results
+		nodeCacheLock.Unlock()
+		return results, nil
+	}
+	nodeCacheLock.Unlock()
+	nodes, err := call(ui, "listnodes")
+	if err != nil {
+		return nil, err
 	}
-	nodes := call(ui, "listnodes")
 
+	nodeCacheLock.Lock()
+	defer nodeCacheLock.Unlock()
 	for _, data := range nodes.Get("nodes").Array() {
 		node := wrapNode(data)
 		results = append(results, node)
@@ -165,21 +271,26 @@ func listNodes(ui *UI) (results []Node) {
 	}
 
 	lastCacheLookup = time.Now()
-	return results
+	return results, nil
 }
-func listNodesThatWillFund(ui *UI) []Node {
+func listNodesThatWillFund(ui *UI) ([]Node, error) {
 	var results []Node
-	nodes := listNodes(ui)
+	nodes, err := listNodes(ui)
+	if err != nil {
+		return nil, err
+	}
 	for _, node := range nodes {
 		if node.optionWillFund != nil {
 			results = append(results, node)
 		}
 	}
-	return results
+	return results, nil
 }
+// listNodesByAliasOrID searches the nodes for term, finding nothing when they
+// can't be listed.
 func listNodesByAliasOrID(ui *UI, term string) []Node {
 	var results []Node
-	nodes := listNodes(ui)
+	nodes, _ := listNodes(ui)
 	for _, node := range nodes {
 		alias := strings.ToLower(node.alias)
 		t := strings.Trim(term, " ")
@@ -191,65 +302,156 @@ func listNodesByAliasOrID(ui *UI, term string) []Node {
 }
 
 
-func listChannels(ui *UI) gjson.Result {
+func listChannels(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "listchannels")
 
 }
-func getChannel(ui *UI, chanID string) gjson.Result {
+// listNodeStats returns the number of channels and the total capacity (sats)
+// of every node, as seen in gossip.
+func listNodeStats(ui *UI) (map[string]NodeStats, error) {
+
+	// cache, replaced rather than changed so callers can keep reading it
+	nodeStatsLock.Lock()
+	if lastStatsLookup.After(time.Now().Add(- cacheFor)) {
+		defer nodeStatsLock.Unlock()
+		return NodeStatsCache, nil
+	}
+	nodeStatsLock.Unlock()
+	stats := make(map[string]NodeStats)
+
+	channels, err := listChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	// the fees of every channel, for the fee history
+	getFeeHistory(ui).RecordGossip(ui, channels)
+	for _, channel := range channels.Get("channels").Array() {
+		source := channel.Get("source").String()
+		s := stats[source]
+		s.channels += 1
+		s.capacity += satOrMsatField(channel, "amount_msat", "satoshis").Sat()
+		stats[source] = s
+	}
+
+	nodeStatsLock.Lock()
+	NodeStatsCache = stats
+	lastStatsLookup = time.Now()
+	nodeStatsLock.Unlock()
+	return stats, nil
+}
+func getChannel(ui *UI, chanID string) (gjson.Result, error) {
 
 	return call(ui, "listchannels", chanID)
 
 }
 
-func getForwards(ui *UI, params map[string]interface{}) gjson.Result {
+func getForwards(ui *UI, params map[string]interface{}) (gjson.Result, error) {
 
 	return call(ui, "listforwards", params)
 
 }
 
-func listFunds(ui *UI, spent bool) gjson.Result {
+func listFunds(ui *UI, spent bool) (gjson.Result, error) {
 
 	return call(ui, "listfunds", spent)
 
 }
 
-func getTransactions(ui *UI) gjson.Result {
+func getTransactions(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "listtransactions")
 
 }
-func getPays(ui *UI) gjson.Result {
+func getPays(ui *UI) (gjson.Result, error) {
 
 	return call(ui, "listpays")
 }
 
-func decodePay(ui *UI, bolt11 string) gjson.Result {
+func decodePay(ui *UI, bolt11 string) (gjson.Result, error) {
 
 	return call(ui, "decodepay", bolt11)
 
 }
 
-func setChannelFee(ui *UI, scid string, base, rate int) gjson.Result {
+func setChannelFee(ui *UI, scid string, base, rate int) (gjson.Result, error) {
+	method := "setchannelfee"
 	params := map[string]interface{} {
 		"id": scid,
 		"base": base,
 		"ppm": rate,
 	}
-	return call(ui, "setchannelfee", params)
+	if getNodeVersion(ui).hasSetChannel() {
+		method = "setchannel"
+		params = map[string]interface{} {
+			"id": scid,
+			"feebase": base,
+			"feeppm": rate,
+		}
+	}
+	results, err := call(ui, method, params)
+	if err != nil {
+		return results, err
+	}
+	for _, channel := range results.Get("channels").Array() {
+		events.Publish(NewEvent(EventFeeChanged, "Fee changed",
+			fmt.Sprintf("Fees of %s set to %d msat + %d ppm", channel.Get("short_channel_id"), base, rate),
+			map[string]interface{}{
+				"short_channel_id": channel.Get("short_channel_id").String(),
+				"channel_id":       channel.Get("channel_id").String(),
+				"peer_id":          channel.Get("peer_id").String(),
+				"base_msat":        base,
+				"ppm":              rate,
+			}))
+	}
+	return results, nil
+}
+
+// closeChannel closes in the background and calls done when it's over.
+func closeChannel(ui *UI, id string, unilateralTimeout int, done func(gjson.Result, error)) {
+	params := map[string]interface{} {
+		"id": id,
+		"unilateraltimeout": unilateralTimeout,
+	}
+	// close waits for the peer up to unilateralTimeout before closing alone
+	timeout := time.Duration(unilateralTimeout)*time.Second + callTimeout("close")
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ui.callAsync(ctx, func(results gjson.Result, err error) {
+		cancel()
+		done(results, err)
+	}, "close", params)
 }
 
-func fundChannel(ui *UI, nodeID string, channelSize int, feerate string, announce bool) gjson.Result {
+// multiFundChannel funds the channels in the background and calls done when
+// it's over.
+func multiFundChannel(ui *UI, destinations []map[string]interface{}, feerate string, done func(gjson.Result, error)) {
 	params := map[string]interface{} {
-		"id": nodeID,
-		"amount": fmt.Sprintf("%dsat", channelSize),
+		"destinations": destinations,
 		"feerate": feerate,
-		"announce": announce,
 	}
-	return call(ui, "fundchannel", params)
+	ui.callAsync(context.Background(), done, "multifundchannel", params)
 }
 
-func offer(ui *UI, amount int, description string) gjson.Result {
+// keysend pays destination without an invoice in the background, and calls
+// done when it's over. The message, if any, goes in the TLV record wallets
+// show keysend messages from. lightningd stops retrying before the call
+// times out.
+func keysend(ui *UI, destination string, amount Msat, message string, done func(gjson.Result, error)) {
+	params := map[string]interface{} {
+		"destination": destination,
+		getNodeVersion(ui).amountKey(): int64(amount),
+		"retry_for": retryFor("keysend"),
+		"label": keysendPaymentLabel(message),
+	}
+	if message != "" {
+		params["extratlvs"] = map[string]string{
+			keysendMessageType: hex.EncodeToString([]byte(message)),
+		}
+	}
+	ui.callAsync(context.Background(), done, "keysend", params)
+}
+
+func offer(ui *UI, amount int, description string) (gjson.Result, error) {
 	params := map[string]interface{} {
 		"amount": fmt.Sprintf("%dsat", amount),
 		"description": description,
@@ -257,3 +459,45 @@ func offer(ui *UI, amount int, description string) gjson.Result {
 
 	return call(ui, "offer", params)
 }
+
+func listPeers(ui *UI) (gjson.Result, error) {
+
+	return call(ui, "listpeers")
+
+}
+
+func funderUpdate(ui *UI, params map[string]interface{}) (gjson.Result, error) {
+
+	return call(ui, "funderupdate", params)
+
+}
+
+// connect connects to a peer in the background and calls done when it's over.
+func connect(ui *UI, id string, done func(gjson.Result, error)) {
+	ui.callAsync(context.Background(), done, "connect", id)
+}
+
+func disconnect(ui *UI, id string, force bool) (gjson.Result, error) {
+	params := map[string]interface{}{
+		"id":    id,
+		"force": force,
+	}
+	return call(ui, "disconnect", params)
+}
+
+// ping pings a peer in the background and calls done when it's over.
+func ping(ui *UI, id string, done func(gjson.Result, error)) {
+	ui.callAsync(context.Background(), done, "ping", id)
+}
+
+func listClosedChannels(ui *UI) (gjson.Result, error) {
+
+	return call(ui, "listclosedchannels")
+
+}
+
+func listAccountEvents(ui *UI) (gjson.Result, error) {
+
+	return call(ui, "bkpr-listaccountevents")
+
+}
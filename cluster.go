@@ -2,22 +2,198 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"github.com/rivo/tview"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 func main() {
 
+	// cluster serve runs the web dashboard and cluster exporter the
+	// Prometheus exporter instead of the terminal UI
+	mode := ""
+	if len(os.Args) > 1 && (os.Args[1] == "serve" || os.Args[1] == "exporter") {
+		mode = os.Args[1]
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	rpcPath := flag.String("rpc", "./lightning-rpc", "Path to lightning-rpc socket")
+	home, _ := os.UserHomeDir()
+	dataDir := flag.String("datadir", filepath.Join(home, ".cluster"), "Directory where cluster keeps its history")
+	retention := flag.Int("metrics-retention", 365, "Days of metrics history to keep")
+	downsampleAfter := flag.Int("metrics-downsample-after", 7, "Days after which metrics are downsampled")
+	resolution := flag.Duration("metrics-resolution", time.Hour, "Resolution of downsampled metrics")
+	sampleInterval := flag.Duration("sample-interval", 10*time.Minute, "How often peer connectivity is sampled (0 to disable)")
+	export := flag.String("export", "", "Export a table ("+strings.Join(exporterNames(), ", ")+") and exit")
+	exportFile := flag.String("export-file", "", "File the export is written to (default stdout)")
+	exportFormat := flag.String("export-format", "", "Export format, csv or json (default from the file extension)")
+	units := flag.String("units", "sat", "Unit amounts are shown in: "+strings.Join(amountUnits, ", "))
+	fiatCurrency := flag.String("fiat-currency", "USD", "Currency fiat amounts are shown in")
+	fiatPrice := flag.Float64("fiat-price", 0, "Fixed price of a bitcoin in --fiat-currency")
+	pricesPath := flag.String("prices", "", "CSV file of date,price lines with the price of a bitcoin over time")
+	priceURL := flag.String("price-url", "", "URL of a JSON document with the current price of a bitcoin")
+	pricePath := flag.String("price-json-path", "price", "Path of the price in the --price-url document (gjson syntax)")
+	refreshPrice := flag.Duration("price-refresh", priceRefresh, "How often the --price-url document is fetched")
+	callTimeout := flag.Duration("call-timeout", defaultCallTimeout, "How long calls wait for lightningd, for methods without a timeout of their own")
+	timeouts := flag.String("timeouts", "", "Timeouts of methods, e.g. getinfo=2s,pay=30m")
+	monitorInterval := flag.Duration("monitor-interval", time.Minute, "How often the node is checked for events to notify (0 to disable)")
+	notifyDisconnect := flag.Duration("notify-disconnect", 10*time.Minute, "Notify peers disconnected for longer than this")
+	notifyForwardMin := flag.Int64("notify-forward-min", 10000, "Notify forwards of at least this many sats")
+	notifyBell := flag.Bool("notify-bell", false, "Ring the terminal bell on events")
+	notifyCommand := flag.String("notify-command", "", "Shell command run on events, e.g. notify-send \"$CLUSTER_EVENT_TITLE\" \"$CLUSTER_EVENT_MESSAGE\"")
+	notifyWebhook := flag.String("notify-webhook", "", "URL events are posted to as JSON")
+	notifySMTP := flag.String("notify-smtp", "", "host:port of the SMTP server events are mailed through")
+	notifySMTPFrom := flag.String("notify-smtp-from", "cluster@localhost", "Sender of the event mails")
+	notifySMTPTo := flag.String("notify-smtp-to", "", "Comma separated recipients of the event mails")
+	notifySMTPUser := flag.String("notify-smtp-user", "", "SMTP user, the password is read from CLUSTER_SMTP_PASSWORD")
+	notifyTest := flag.Bool("notify-test", false, "Send a test event to the notifiers and exit")
+	hooksPath := flag.String("hooks", "", "JSON file of commands run on events (default hooks.json in --datadir)")
+	listen := flag.String("listen", "127.0.0.1:8080", "Address cluster serve and cluster exporter listen on")
+	token := flag.String("token", os.Getenv("CLUSTER_TOKEN"), "Token the web dashboard and the exporter ask for (default from CLUSTER_TOKEN)")
+	metricsCache := flag.Duration("metrics-cache", 30*time.Second, "How long cluster exporter reuses the metrics of a scrape")
+	fake := flag.String("fake", "", "Run against a fake node replying with the fixtures of a schema ("+strings.Join(fakeSchemas(), ", ")+")")
 	flag.Parse()
 
+	var log *Log
+	if *export != "" || mode != "" {
+		log = NewConsoleLog(os.Stderr)
+	} else {
+		log = NewLog()
+	}
+	defer log.Close()
+
+	rpc := *rpcPath
+	if *fake != "" {
+		node, err := StartFakeNode(*fake, filepath.Join(os.TempDir(), fmt.Sprintf("cluster-fake-%d.sock", os.Getpid())))
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't start the fake node:", err)
+			os.Exit(1)
+		}
+		defer node.Close()
+		rpc = node.path
+		// the fake node's fees and metrics stay out of the real history
+		dir, err := os.MkdirTemp("", "cluster-fake-")
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "Can't create the fake node's data directory:", err)
+			os.Exit(1)
+		}
+		defer os.RemoveAll(dir)
+		*dataDir = dir
+	}
+
 	ui := &UI{
 		tview.NewApplication(),
 		tview.NewPages(),
 		make(map[string]tview.Primitive),
 		nil,
-		NewLog(),
-		*rpcPath,
+		log,
+		rpc,
+		*dataDir,
+		NewMetricStore(filepath.Join(*dataDir, "metrics"),
+			time.Duration(*retention)*24*time.Hour,
+			time.Duration(*downsampleAfter)*24*time.Hour,
+			*resolution),
+	}
+
+	defaultCallTimeout = *callTimeout
+	if err := parseTimeouts(*timeouts); err != nil {
+		fmt.Fprintln(os.Stderr, "Incorrect --timeouts:", err)
+		os.Exit(1)
+	}
+
+	if !validUnit(*units) {
+		fmt.Fprintln(os.Stderr, "Unknown unit", *units+", expected one of", strings.Join(amountUnits, ", "))
+		os.Exit(1)
+	}
+	amountUnit = *units
+
+	if *refreshPrice <= 0 {
+		fmt.Fprintln(os.Stderr, "--price-refresh must be positive")
+		os.Exit(1)
+	}
+	priceRefresh = *refreshPrice
+	provider, err := newPriceProvider(*fiatCurrency, *fiatPrice, *pricesPath, *priceURL, *pricePath)
+	if err == nil {
+		priceProvider = provider
+	} else if err != errNoPriceSource {
+		fmt.Fprintln(os.Stderr, "Can't read prices:", err)
+		os.Exit(1)
+	}
+
+	forwardNotifyMin = Sats(*notifyForwardMin)
+	if *notifyBell {
+		notifiers = append(notifiers, BellNotifier{})
 	}
+	if *notifyCommand != "" {
+		notifiers = append(notifiers, CommandNotifier{*notifyCommand})
+	}
+	if *notifyWebhook != "" {
+		notifiers = append(notifiers, WebhookNotifier{*notifyWebhook})
+	}
+	if *notifySMTP != "" {
+		if *notifySMTPTo == "" {
+			fmt.Fprintln(os.Stderr, "--notify-smtp needs --notify-smtp-to")
+			os.Exit(1)
+		}
+		notifiers = append(notifiers, SMTPNotifier{*notifySMTP, *notifySMTPFrom, strings.Split(*notifySMTPTo, ","), *notifySMTPUser})
+	}
+	if *notifyTest {
+		if err := SendTestNotification(); err != nil {
+			fmt.Fprintln(os.Stderr, "Notification test failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if skipped, err := ui.metrics.Open(); err != nil {
+		ui.log.Warn("Can't open metrics store: " + err.Error() + "\n")
+	} else if skipped > 0 {
+		ui.log.Warn(fmt.Sprintf("Skipped %d corrupt lines of the metrics store %s\n", skipped, ui.metrics.dir))
+	}
+	defer ui.metrics.Close()
+
+	if *export != "" {
+		if err := ui.Export(*export, *exportFile, *exportFormat); err != nil {
+			log.Close()
+			fmt.Fprintln(os.Stderr, "Export failed:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	switch mode {
+	case "serve":
+		if err := ui.Serve(*listen, *token); err != nil {
+			log.Close()
+			fmt.Fprintln(os.Stderr, "Can't serve the dashboard:", err)
+			os.Exit(1)
+		}
+		return
+	case "exporter":
+		if err := ui.ServeMetrics(*listen, *token, *metricsCache); err != nil {
+			log.Close()
+			fmt.Fprintln(os.Stderr, "Can't serve metrics:", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if *hooksPath == "" {
+		*hooksPath = filepath.Join(*dataDir, "hooks.json")
+	}
+	if err := loadHooks(*hooksPath); err != nil {
+		fmt.Fprintln(os.Stderr, "Can't read hooks from", *hooksPath+":", err)
+		os.Exit(1)
+	}
+
+	ui.StartSampler(*sampleInterval)
+	ui.StartToasts()
+	ui.StartNotifiers()
+	ui.StartHooks()
+	NewMonitor(ui, *notifyDisconnect).Start(*monitorInterval)
 
 	ui.Run()
 }
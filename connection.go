@@ -0,0 +1,79 @@
+package main
+
+import (
+	"errors"
+	"github.com/gdamore/tcell/v2"
+	"sync"
+	"time"
+)
+
+const reconnectInterval = 2 * time.Second
+
+var connectionLost bool
+var connectionLock sync.Mutex
+
+var errConnectionLost = errors.New("waiting for lightningd to accept connections again")
+
+// isConnectionLost tells if lightningd was found unreachable and isn't back
+// yet, calls fail right away meanwhile.
+func isConnectionLost() bool {
+	connectionLock.Lock()
+	defer connectionLock.Unlock()
+	return connectionLost
+}
+
+// ConnectionLost shows a banner and waits in the background for lightningd
+// to be back.
+func (ui *UI) ConnectionLost() {
+	connectionLock.Lock()
+	defer connectionLock.Unlock()
+
+	if connectionLost {
+		return
+	}
+	connectionLost = true
+	// calls are made from the UI's event loop too, which has to be done
+	// with them to apply updates
+	if topBar != nil {
+		go ui.app.QueueUpdateDraw(func() {
+			topBar.SetText(" Connection to lightningd lost, reconnecting...")
+			topBar.SetTextColor(tcell.ColorWhite)
+			topBar.SetBackgroundColor(tcell.ColorRed)
+		})
+	}
+	go ui.reconnect()
+}
+
+// reconnect waits for lightningd to accept connections again, then removes
+// the banner and reloads the page shown. The node may have been upgraded in
+// the meantime, so its version is asked again.
+func (ui *UI) reconnect() {
+	for checkSocket(ui.rpcPath) != nil {
+		time.Sleep(reconnectInterval)
+	}
+
+	connectionLock.Lock()
+	connectionLost = false
+	connectionLock.Unlock()
+
+	nodeVersionLock.Lock()
+	nodeVersion = nil
+	nodeVersionLock.Unlock()
+
+	if topBar == nil {
+		return
+	}
+	ui.app.QueueUpdateDraw(func() {
+		topBar.SetText(topBarText)
+		topBar.SetTextColor(TopbarTextColor)
+		topBar.SetBackgroundColor(MainColor)
+		ui.log.Ok("Reconnected to lightningd\n")
+
+		name, _ := ui.pages.GetFrontPage()
+		inMenu := ui.app.GetFocus() == ui.menu
+		ui.ReloadPage(name)
+		if inMenu {
+			ui.FocusMenu()
+		}
+	})
+}
@@ -9,8 +9,6 @@ import (
 	"io"
 	"math"
 	"sort"
-	"strconv"
-	"strings"
 	"time"
 )
 
@@ -52,26 +50,140 @@ func (ic *InfoColumn) Print(w io.Writer) {
 
 type Activity struct {
 	date        time.Time
-	amount      int64
-	fees        int64
+	amount      Msat
+	fees        Msat
 	operation   string
 	description string
 }
 
-func findOutput(outputs []gjson.Result, txid string, outputIdx int64) (int64, error) {
+// how far back the recent activity goes
+const activityPeriod = 31 * 24 * time.Hour
+
+// getActivities returns the payments sent and the invoices paid since since,
+// most recent first.
+func getActivities(ui *UI, localID string, since time.Time) ([]*Activity, error) {
+	var activities []*Activity
+
+	// pays
+	results, err := getPays(ui)
+	if err != nil {
+		return nil, err
+	}
+	pays := results.Get("pays").Array()
+
+	for _, pay := range pays {
+		// date
+		date := time.Unix(pay.Get("created_at").Int(), 0)
+
+		if date.After(since) {
+
+			status := pay.Get("status").String()
+			// only completed or pending pays for the past week
+
+			if status == "complete" || status == "pending" {
+				// amount
+				amount := msatValue(pay, "amount")
+				amountSent := msatValue(pay, "amount_sent")
+
+				// operation
+				destination := pay.Get("destination").String()
+				payee := listNode(ui, destination)
+				bolt11 := pay.Get("bolt11").String()
+				description := pay.Get("label").String()
+				kind := "sent"
+				if message, isKeysend := keysendMessage(description); isKeysend {
+					kind = "keysend"
+					description = message
+				}
+				var operation string
+				if destination == localID {
+					operation = "[greenyellow]rebalance"
+				} else {
+					if status == "pending" {
+						operation = "[violet]pending " + kind + " to " + payee.alias
+					} else {
+						operation = "[darkviolet]" + kind + " to " + payee.alias
+					}
+				}
+
+				// description
+				if bolt11 != "" {
+					if bolt11Decoded, err := decodePay(ui, bolt11); err == nil {
+						description = bolt11Decoded.Get("description").String()
+					}
+				}
+
+				description = " " + formatDesc(description)
+
+				activities = append(activities, &Activity{
+					date,
+					amount,
+					amountSent - amount,
+					operation,
+					description,
+				})
+			}
+		}
+	}
+
+	// invoices
+
+	results, err = getInvoices(ui)
+	if err != nil {
+		return nil, err
+	}
+	invoices := results.Get("invoices").Array()
+
+	for _, invoice := range invoices {
+		// only paid invoices of the period
+		paidAt := invoice.Get("paid_at").Int()
+
+		date := time.Unix(paidAt, 0)
+		if date.After(since) {
+
+			// amount
+			amount := msatField(invoice, "amount_received_msat", "msatoshi_received")
+
+			// operation
+			operation := "[green]received"
+
+			// description
+			description := invoice.Get("description").String()
+
+			description = " " + formatDesc(description)
+
+			activities = append(activities, &Activity{
+				date,
+				amount,
+				0,
+				operation,
+				description,
+			})
+		}
+	}
+
+	sort.Slice(activities, func(i, j int) bool {
+		a1 := activities[i]
+		a2 := activities[j]
+		return a2.date.Before(a1.date)
+	})
+	return activities, nil
+}
+
+func findOutput(outputs []gjson.Result, txid string, outputIdx int64) (Msat, error) {
 	for _, output := range outputs {
 		oTxid := output.Get("txid").String()
 		oIdx := output.Get("output").Int()
 		if oTxid == txid && oIdx == outputIdx {
-			return output.Get("value").Int(), nil
+			return satOrMsatField(output, "amount_msat", "value"), nil
 		}
 	}
 	return 0, errors.New("output not found")
 }
 func calculateSpentFees(transactions, funds gjson.Result) int64 {
-	fees := int64(0)
+	fees := Msat(0)
 	for _, tx := range transactions.Get("transactions").Array() {
-		vin := int64(0)
+		vin := Msat(0)
 		for _, input := range tx.Get("inputs").Array() {
 			value, err := findOutput(funds.Get("outputs").Array(), input.Get("txid").String(), input.Get("index").Int())
 
@@ -81,18 +193,43 @@ func calculateSpentFees(transactions, funds gjson.Result) int64 {
 
 		}
 		if vin > 0 {
-			vout := int64(0)
+			vout := Msat(0)
 			for _, output := range tx.Get("outputs").Array() {
-				sats, err := strconv.Atoi(strings.Replace(output.Get("satoshis").String(), "msat", "", 1))
-
-				if err == nil {
-					vout += int64(sats) / 1000
-				}
+				vout += msatField(output, "amount_msat", "satoshis")
 			}
 			fees += vin - vout
 		}
 	}
-	return fees
+	return fees.Sat()
+}
+
+// activityCells are the cells of an activity in the recent activity table:
+// date, operation, amount, fees, the amount in fiat with showFiat, and
+// description.
+func activityCells(activity *Activity, showFiat bool) []string {
+	var amountColor string
+	if activity.operation == "[green]received" {
+		amountColor = "[green]"
+	} else {
+		amountColor = "[red]"
+	}
+	var feesFormatted string
+	if activity.fees == 0 {
+		feesFormatted = ""
+	} else {
+		feesFormatted = "[red]" + activity.fees.Format()
+	}
+	cells := []string{
+		"[grey] " + activity.date.Format("2006-01-02 15:04"),
+		activity.operation,
+		amountColor + activity.amount.Format(),
+		feesFormatted,
+	}
+	if showFiat {
+		// at the price of the day of the activity
+		cells = append(cells, amountColor+formatFiatAmount(activity.amount, activity.date))
+	}
+	return append(cells, "[white]"+activity.description)
 }
 
 func formatDesc(desc string) string {
@@ -103,127 +240,234 @@ func formatDesc(desc string) string {
 		return desc
 	}
 }
-func dashPage(ui *UI) tview.Primitive {
+// NodeAddress is an address the node is announced on.
+type NodeAddress struct {
+	Type    string `json:"type"`
+	Address string `json:"address"`
+}
 
-	// Node Info
-	infoPane := tview.NewTextView()
-	infoPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Node Info ")
-	infoPane.SetDynamicColors(true)
+// NodeSummary is what the overview shows about the node, for the terminal,
+// the web dashboard and the metrics exporter alike. Amounts are in sats.
+type NodeSummary struct {
+	Alias            string             `json:"alias"`
+	ID               string             `json:"id"`
+	Network          string             `json:"network"`
+	Blockheight      int64              `json:"blockheight"`
+	Binding          string             `json:"binding"`
+	Announce         []NodeAddress      `json:"announce"`
+	Peers            int64              `json:"peers"`
+	ActiveChannels   int64              `json:"active_channels"`
+	InactiveChannels int64              `json:"inactive_channels"`
+	PendingChannels  int64              `json:"pending_channels"`
+	LargeChannels    bool               `json:"large_channels"`
+	MinCapacity      int64              `json:"min_capacity_sat"`
+	FeesCollected    int64              `json:"fees_collected_sat"`
+	FeesSpent        int64              `json:"fees_spent_onchain_sat"`
+	ProfitLoss       int64              `json:"profit_loss_sat"`
+	OnChainFunds     int64              `json:"onchain_sat"`
+	UTXOs            int64              `json:"utxos"`
+	Outbound         int64              `json:"outbound_sat"`
+	Inbound          int64              `json:"inbound_sat"`
+	SmallestChannel  int64              `json:"smallest_channel_sat"`
+	BiggestChannel   int64              `json:"biggest_channel_sat"`
+	DefaultBaseFee   int64              `json:"default_base_fee_msat"`
+	DefaultFeeRate   int64              `json:"default_fee_rate_ppm"`
+	Feerates         map[string]Feerate `json:"feerates_perkb"`
+}
 
-	info := getInfo(ui)
-	config := getConfig(ui)
+// feerateNames are the on-chain fee rates shown, in order, with their labels.
+var feerateNames = []struct{ name, label string }{
+	{"opening", "Opening"},
+	{"mutual_close", "Mutual close"},
+	{"unilateral_close", "Unilateral close"},
+	{"delayed_to_us", "Delayed to us"},
+	{"htlc_resolution", "HTLC resolution"},
+	{"penalty", "Penalty"},
+	{"min_acceptable", "Min acceptable"},
+	{"max_acceptable", "Max acceptable"},
+}
 
-	ic := NewInfoColumn("[deepskyblue]", "[white]")
-	ic.AddRow("Node alias", info.Get("alias").String())
-	ic.AddRow("Node pubkey", info.Get("id").String())
-	ic.AddRow("Network", info.Get("network").String())
-	ic.AddRow("Blockheight", info.Get("blockheight").String())
-	ic.AddRow("Bound to", info.Get("binding.0.address").String()+":"+info.Get("binding.0.port").String())
-	for _, announce := range info.Get("address").Array() {
-		ic.AddRow("Announce "+announce.Get("type").String(), announce.Get("address").String()+":"+announce.Get("port").String())
+func getNodeSummary(ui *UI) (*NodeSummary, error) {
+	info, err := getInfo(ui)
+	if err != nil {
+		return nil, err
 	}
-	ic.AddRow("Peers", info.Get("num_peers").String())
-	activeChannels := info.Get("num_active_channels").String()
-	ic.AddRow("Active channels", activeChannels)
-	ic.AddRow("Offline channels", info.Get("num_inactive_channels").String())
-	ic.AddRow("Pending channels", info.Get("num_pending_channels").String())
-	var largeChannels string
-	if config.Get("large-channels").Bool() {
-		largeChannels = "Supported"
-	} else {
-		largeChannels = "[red]Not supported"
+	config, err := getConfig(ui)
+	if err != nil {
+		return nil, err
+	}
+	funds, err := listFunds(ui, true) // list both confirmed and spent funds
+	if err != nil {
+		return nil, err
+	}
+	transactions, err := getTransactions(ui)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := getFeerates(ui)
+	if err != nil {
+		return nil, err
 	}
-	ic.AddRow("Large channels", largeChannels)
-	ic.AddRow("Mininum capacity", formatSats(config.Get("min-capacity-sat").Int()))
-
-	collectedFees := info.Get("msatoshi_fees_collected").Int() / 1000
-
-	ic.AddRow("Fees collected (sats)", "[yellow]"+formatSats(collectedFees))
-
-	// Available Funds
-	fundsPane := tview.NewTextView()
-	fundsPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Available funds ")
-	fundsPane.SetDynamicColors(true)
-
-	funds := listFunds(ui, true) // list both confirmed and spent funds
-
-	transactions := getTransactions(ui)
-
-	spentFees := calculateSpentFees(transactions, funds)
 
-	ic.AddRow("Fees spent on-chain", "[yellow]"+formatSats(spentFees))
-	profitLoss := collectedFees - spentFees
-	var plPrefix string
-	if profitLoss > 0 {
-		plPrefix = "[green]"
-	} else {
-		plPrefix = "[red]"
+	s := &NodeSummary{
+		Alias:            info.Get("alias").String(),
+		ID:               info.Get("id").String(),
+		Network:          info.Get("network").String(),
+		Blockheight:      info.Get("blockheight").Int(),
+		Binding:          info.Get("binding.0.address").String() + ":" + info.Get("binding.0.port").String(),
+		Peers:            info.Get("num_peers").Int(),
+		ActiveChannels:   info.Get("num_active_channels").Int(),
+		InactiveChannels: info.Get("num_inactive_channels").Int(),
+		PendingChannels:  info.Get("num_pending_channels").Int(),
+		LargeChannels:    config.Get("large-channels").Bool(),
+		MinCapacity:      config.Get("min-capacity-sat").Int(),
+		DefaultBaseFee:   config.Get("fee-base").Int(),
+		DefaultFeeRate:   config.Get("fee-per-satoshi").Int(),
+		Feerates:         make(map[string]Feerate),
+	}
+	for _, announce := range info.Get("address").Array() {
+		s.Announce = append(s.Announce, NodeAddress{
+			announce.Get("type").String(),
+			announce.Get("address").String() + ":" + announce.Get("port").String(),
+		})
 	}
-	ic.AddRow("Profit/Loss", plPrefix+formatSats(profitLoss))
-	ic.Print(infoPane)
 
-	fc := NewInfoColumn("[deepskyblue]", "[yellow]")
-	onChainFunds := int64(0)
-	numUtxo := int64(0)
+	s.FeesCollected = msatField(info, "fees_collected_msat", "msatoshi_fees_collected").Sat()
+	s.FeesSpent = calculateSpentFees(transactions, funds)
+	s.ProfitLoss = s.FeesCollected - s.FeesSpent
 
 	for _, output := range funds.Get("outputs").Array() {
 		if output.Get("status").String() == "confirmed" {
-			onChainFunds += output.Get("value").Int()
-			numUtxo += 1
+			s.OnChainFunds += satOrMsatField(output, "amount_msat", "value").Sat()
+			s.UTXOs += 1
 		}
 	}
 
-	outboundFunds := int64(0)
 	totalChannelFunds := int64(0)
-	minChan := float64(math.MaxInt64)
-	var maxChan float64
-
+	minChan := int64(math.MaxInt64)
 	for _, output := range funds.Get("channels").Array() {
-		outboundFunds += output.Get("channel_sat").Int()
-		chanSize := output.Get("channel_total_sat").Int()
+		s.Outbound += satOrMsatField(output, "our_amount_msat", "channel_sat").Sat()
+		chanSize := satOrMsatField(output, "amount_msat", "channel_total_sat").Sat()
 		totalChannelFunds += chanSize
-		minChan = math.Min(float64(chanSize), minChan)
-		maxChan = math.Max(float64(chanSize), maxChan)
+		if chanSize < minChan {
+			minChan = chanSize
+		}
+		if chanSize > s.BiggestChannel {
+			s.BiggestChannel = chanSize
+		}
+	}
+	if minChan != math.MaxInt64 {
+		s.SmallestChannel = minChan
 	}
+	s.Inbound = totalChannelFunds - s.Outbound
 
-	fc.AddRow("On-chain capacity", formatSats(onChainFunds)+" [white]in [yellow]"+fmt.Sprintf("%d [white]UTXOs", numUtxo))
-	fc.AddRow("Outbound LN capacity", formatSats(outboundFunds)+" [white]in [yellow]"+fmt.Sprintf("%s [white]channels", activeChannels))
-	fc.AddRow("Total node worth", formatSats(onChainFunds+outboundFunds))
-	fc.AddRow("Inbound LN capacity", formatSats(totalChannelFunds-outboundFunds))
-	fc.AddRow("Smallest channel", formatSats(int64(minChan)))
-	fc.AddRow("Biggest channel", formatSats(int64(maxChan)))
-	fc.Print(fundsPane)
+	for _, feerate := range feerateNames {
+		s.Feerates[feerate.name] = FeeratePerKb(rates.Get("perkb." + feerate.name).Int())
+	}
+	return s, nil
+}
+
+// DashPane is one of the panes of the overview.
+type DashPane struct {
+	title  string
+	column *InfoColumn
+}
+
+// dashPanes lays the summary out in the panes of the overview: node info,
+// available funds, trends, and off-chain and on-chain fees.
+func dashPanes(ui *UI, s *NodeSummary) []DashPane {
+	ic := NewInfoColumn("[deepskyblue]", "[white]")
+	ic.AddRow("Node alias", s.Alias)
+	ic.AddRow("Node pubkey", s.ID)
+	ic.AddRow("Network", s.Network)
+	ic.AddRow("Blockheight", fmt.Sprint(s.Blockheight))
+	ic.AddRow("Bound to", s.Binding)
+	for _, announce := range s.Announce {
+		ic.AddRow("Announce "+announce.Type, announce.Address)
+	}
+	ic.AddRow("Peers", fmt.Sprint(s.Peers))
+	ic.AddRow("Active channels", fmt.Sprint(s.ActiveChannels))
+	ic.AddRow("Offline channels", fmt.Sprint(s.InactiveChannels))
+	ic.AddRow("Pending channels", fmt.Sprint(s.PendingChannels))
+	var largeChannels string
+	if s.LargeChannels {
+		largeChannels = "Supported"
+	} else {
+		largeChannels = "[red]Not supported"
+	}
+	ic.AddRow("Large channels", largeChannels)
+	ic.AddRow("Mininum capacity", formatSats(s.MinCapacity))
+	ic.AddRow("Fees collected ("+amountUnit+")", "[yellow]"+ui.withFiat(formatAmount(s.FeesCollected), s.FeesCollected))
+	ic.AddRow("Fees spent on-chain", "[yellow]"+ui.withFiat(formatAmount(s.FeesSpent), s.FeesSpent))
+	var plPrefix string
+	if s.ProfitLoss > 0 {
+		plPrefix = "[green]"
+	} else {
+		plPrefix = "[red]"
+	}
+	ic.AddRow("Profit/Loss", plPrefix+ui.withFiat(formatAmount(s.ProfitLoss), s.ProfitLoss))
 
-	// Off-chain fees
-	// On-chain fees
-	offChainFeesPane := tview.NewTextView()
-	offChainFeesPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Default off-chain channel fees  ")
-	offChainFeesPane.SetDynamicColors(true)
+	fc := NewInfoColumn("[deepskyblue]", "[yellow]")
+	fc.AddRow("On-chain capacity", ui.withFiat(formatAmount(s.OnChainFunds), s.OnChainFunds)+" [white]in [yellow]"+fmt.Sprintf("%d [white]UTXOs", s.UTXOs))
+	fc.AddRow("Outbound LN capacity", ui.withFiat(formatAmount(s.Outbound), s.Outbound)+" [white]in [yellow]"+fmt.Sprintf("%d [white]channels", s.ActiveChannels))
+	fc.AddRow("Total node worth", ui.withFiat(formatAmount(s.OnChainFunds+s.Outbound), s.OnChainFunds+s.Outbound))
+	fc.AddRow("Inbound LN capacity", ui.withFiat(formatAmount(s.Inbound), s.Inbound))
+	fc.AddRow("Smallest channel", formatAmount(s.SmallestChannel))
+	fc.AddRow("Biggest channel", formatAmount(s.BiggestChannel))
+
+	trends := NewInfoColumn("[deepskyblue]", "[yellow]")
+	trendPeriod := 30 * 24 * time.Hour
+	trends.AddRow("On-chain capacity", ui.metrics.Sparkline("node.onchain_funds", trendPeriod, 30))
+	trends.AddRow("Outbound LN capacity", ui.metrics.Sparkline("node.outbound", trendPeriod, 30))
+	trends.AddRow("Inbound LN capacity", ui.metrics.Sparkline("node.inbound", trendPeriod, 30))
+	trends.AddRow("Fees collected", ui.metrics.Sparkline("node.fees_collected", trendPeriod, 30))
+	trends.AddRow("Opening fee rate", ui.metrics.Sparkline("feerate.opening", trendPeriod, 30))
 
 	l2Fees := NewInfoColumn("[deepskyblue]", "[orange]")
-	l2Fees.AddRow("Default base fee", formatSats(config.Get("fee-base").Int()))
-	l2Fees.AddRow("Default fee rate", formatSats(config.Get("fee-per-satoshi").Int()))
+	l2Fees.AddRow("Default base fee", formatSats(s.DefaultBaseFee))
+	l2Fees.AddRow("Default fee rate", formatSats(s.DefaultFeeRate))
 
-	l2Fees.Print(offChainFeesPane)
+	l1Fees := NewInfoColumn("[deepskyblue]", "[orange]")
+	for _, feerate := range feerateNames {
+		value := s.Feerates[feerate.name].String()
+		if feerate.name == "mutual_close" {
+			value = "[green]" + value
+		}
+		l1Fees.AddRow(feerate.label, value)
+	}
 
-	// On-chain fees
-	onChainFeesPane := tview.NewTextView()
-	onChainFeesPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Current on-chain fee rates ")
-	onChainFeesPane.SetDynamicColors(true)
+	return []DashPane{
+		{"Node Info", ic},
+		{"Available funds", fc},
+		{"Trends (30 days)", trends},
+		{"Default off-chain channel fees", l2Fees},
+		{"Current on-chain fee rates", l1Fees},
+	}
+}
 
-	rates := getFeerates(ui)
+func dashPage(ui *UI) tview.Primitive {
+	summary, err := getNodeSummary(ui)
+	if err != nil {
+		return ui.NewErrorPage("dash", err)
+	}
 
-	l1Fees := NewInfoColumn("[deepskyblue]", "[orange]")
-	l1Fees.AddRow("Opening", formatSats(rates.Get("perkb.opening").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Mutual close", "[green]"+formatSats(rates.Get("perkb.mutual_close").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Unilateral close", formatSats(rates.Get("perkb.unilateral_close").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Delayed to us", formatSats(rates.Get("perkb.delayed_to_us").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("HTLC resolution", formatSats(rates.Get("perkb.htlc_resolution").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Penalty", formatSats(rates.Get("perkb.penalty").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Min acceptable", formatSats(rates.Get("perkb.min_acceptable").Int()/1024)+" sat/vB")
-	l1Fees.AddRow("Max acceptable", formatSats(rates.Get("perkb.max_acceptable").Int()/1024)+" sat/vB")
+	ui.recordMetric("node.onchain_funds", float64(summary.OnChainFunds))
+	ui.recordMetric("node.outbound", float64(summary.Outbound))
+	ui.recordMetric("node.inbound", float64(summary.Inbound))
+	ui.recordMetric("node.fees_collected", float64(summary.FeesCollected))
+	for _, name := range []string{"opening", "mutual_close", "unilateral_close"} {
+		ui.recordMetric("feerate."+name, summary.Feerates[name].SatPerVByte())
+	}
 
-	l1Fees.Print(onChainFeesPane)
+	var views []*tview.TextView
+	for _, pane := range dashPanes(ui, summary) {
+		view := tview.NewTextView()
+		view.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" " + pane.title + " ")
+		view.SetDynamicColors(true)
+		pane.column.Print(view)
+		views = append(views, view)
+	}
+	infoPane, fundsPane, trendsPane, offChainFeesPane, onChainFeesPane := views[0], views[1], views[2], views[3], views[4]
 
 	// Recent activityTable
 
@@ -234,7 +478,12 @@ func dashPage(ui *UI) tview.Primitive {
 	activityTable.AddColumnHeader("\n[bold]date", tview.AlignCenter)
 	activityTable.AddColumnHeader("\noperation", tview.AlignRight)
 	activityTable.AddColumnHeader("\namount", tview.AlignRight)
-	activityTable.AddColumnHeader("\nfees\n(sats)", tview.AlignRight)
+	activityTable.AddColumnHeader("\nfees\n("+amountUnit+")", tview.AlignRight)
+	showFiat := getSettings(ui).ShowFiat && priceProvider != nil
+	if showFiat {
+		activityTable.AddColumnHeader("\namount\n("+priceProvider.Currency()+")", tview.AlignRight)
+	}
+	descriptionColumn := activityTable.GetColumnCount()
 	activityTable.AddColumnHeader("\n description", tview.AlignLeft)
 	activityTable.Separator(16)
 
@@ -253,132 +502,43 @@ func dashPage(ui *UI) tview.Primitive {
 		}
 	})
 
-	var activities []*Activity
-
-	// pays
-	pays := getPays(ui).Get("pays").Array()
-
-	month := 24 * time.Hour * 31
-
-	lastMonth := time.Now().Add(-month)
-
-	for _, pay := range pays {
-		// date
-		date := time.Unix(pay.Get("created_at").Int(), 0)
-
-		if date.After(lastMonth) {
-
-			status := pay.Get("status").String()
-			// only completed or pending pays for the past week
-
-			if status == "complete" || status == "pending" {
-				// amount
-				amount, _ := Mstoi(pay.Get("amount_msat").String())
-				amountSent, _ := Mstoi(pay.Get("amount_sent_msat").String())
-
-				// operation
-				destination := pay.Get("destination").String()
-				payee := listNode(ui, destination)
-				var operation string
-				if destination == info.Get("id").String() {
-					operation = "[greenyellow]rebalance"
-				} else {
-					if status == "pending" {
-						operation = "[violet]pending to " + payee.alias
-					} else {
-						operation = "[darkviolet]sent to " + payee.alias
-					}
-				}
-
-				// description
-				bolt11 := pay.Get("bolt11").String()
-				var bolt11Decoded gjson.Result
-				var description string
-
-				if bolt11 != "" {
-					bolt11Decoded = decodePay(ui, bolt11)
-					description = bolt11Decoded.Get("description").String()
-				} else {
-					description = pay.Get("label").String()
-				}
-
-				description = " " + formatDesc(description)
-
-				activities = append(activities, &Activity{
-					date,
-					amount / 1000,
-					(amountSent - amount) / 1000,
-					operation,
-					description,
-				})
-			}
-		}
+	activities, err := getActivities(ui, summary.ID, time.Now().Add(-activityPeriod))
+	if err != nil {
+		return ui.NewErrorPage("dash", err)
 	}
-
-	// invoices
-
-	invoices := getInvoices(ui).Get("invoices").Array()
-
-	for _, invoice := range invoices {
-		// only paid invoices for the past month
-		paidAt := invoice.Get("paid_at").Int()
-
-		date := time.Unix(paidAt, 0)
-		if date.After(lastMonth) {
-
-			// amount
-			amount := invoice.Get("msatoshi_received").Int()
-
-			// operation
-			operation := "[green]received"
-
-			// description
-			description := invoice.Get("description").String()
-
-			description = " " + formatDesc(description)
-
-			activities = append(activities, &Activity{
-				date,
-				amount / 1000,
-				0,
-				operation,
-				description,
-			})
+	activityTable.SetExport(ui, "activity", func() Export {
+		return activityExport(activities)
+	})
+	// the full ledger, for accounting
+	activityTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() == 'L' {
+			if ui.HasPage("export") {
+				ui.DeletePage("export")
+			}
+			ledger, err := getLedger(ui)
+			if err != nil {
+				ui.log.Warn("Can't export the ledger: " + err.Error() + "\n")
+				return nil
+			}
+			ui.AddPage("export", ui.NewExportPage("ledger", ledgerExport(ledger), activityTable), true, true)
+			ui.SetFocus("export")
+			return nil
 		}
-	}
-
-	sort.Slice(activities, func(i, j int) bool {
-		a1 := activities[i]
-		a2 := activities[j]
-		return a2.date.Before(a1.date)
+		return event
 	})
-	totalFees := int64(0)
 
-	for idx, activity := range activities {
+	totalFees := Msat(0)
 
-		activityTable.SetCell(idx+rowOffset, 0,
-			tview.NewTableCell("[grey] "+activity.date.Format("2006-01-02 15:04")).SetAlign(tview.AlignCenter))
-		activityTable.SetCell(idx+rowOffset, 1,
-			tview.NewTableCell(activity.operation).SetAlign(tview.AlignRight))
-		var amountColor string
-		if activity.operation == "[green]received" {
-			amountColor = "[green]"
-		} else {
-			amountColor = "[red]"
-		}
-		var feesFormatted string
-		if activity.fees == 0 {
-			feesFormatted = ""
-		} else {
-			feesFormatted = "[red]" + formatSats(activity.fees)
+	for idx, activity := range activities {
+		for col, cell := range activityCells(activity, showFiat) {
+			align := tview.AlignRight
+			if col == 0 {
+				align = tview.AlignCenter
+			} else if col == descriptionColumn {
+				align = tview.AlignLeft
+			}
+			activityTable.SetCell(idx+rowOffset, col, tview.NewTableCell(cell).SetAlign(align))
 		}
-		activityTable.SetCell(idx+rowOffset, 2,
-			tview.NewTableCell(amountColor+formatSats(activity.amount)).SetAlign(tview.AlignRight))
-		activityTable.SetCell(idx+rowOffset, 3,
-			tview.NewTableCell(feesFormatted).SetAlign(tview.AlignRight))
-		activityTable.SetCell(idx+rowOffset, 4,
-			tview.NewTableCell("[white]"+activity.description).SetAlign(tview.AlignLeft))
-
 		totalFees += activity.fees
 	}
 
@@ -388,7 +548,7 @@ func dashPage(ui *UI) tview.Primitive {
 
 	// Total inbound
 	activityTable.SetCell(currentRow, 3,
-		tview.NewTableCell("[red]" + formatSats(totalFees)).SetAlign(tview.AlignRight))
+		tview.NewTableCell("[red]" + totalFees.Format()).SetAlign(tview.AlignRight))
 
 	dash := tview.NewFlex()
 	dashLeft := tview.NewFlex()
@@ -396,6 +556,7 @@ func dashPage(ui *UI) tview.Primitive {
 	dashLeft.SetDirection(tview.FlexRow)
 	dashLeft.AddItem(infoPane, 0, 2, false)
 	dashLeft.AddItem(fundsPane, 0, 1, false)
+	dashLeft.AddItem(trendsPane, 8, 0, false)
 	feesPane := tview.NewFlex()
 	feesPane.SetDirection(tview.FlexColumn)
 	feesPane.AddItem(offChainFeesPane, 0, 1, false)
@@ -1,10 +1,355 @@
 package main
 
 import (
+	"fmt"
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"sort"
+	"strconv"
+	"strings"
 )
 
+type FunderSettings struct {
+	policy                               string
+	policyMod                            int64
+	minTheirFunding                      int64
+	maxTheirFunding                      int64
+	reserveTank                          int64
+	fuzzPercent                          int64
+	leaseFeeBaseMsat                     Msat
+	leaseFeeBasis                        int64
+	channelFeeMaxBaseMsat                Msat
+	channelFeeMaxProportionalThousandths int64
+	compactLease                         string
+}
+
+type Lease struct {
+	shortChannelID string
+	remoteAlias    string
+	amount         int64
+	fee            int64
+	expiry         int64
+}
+
+type LiquidityAd struct {
+	node  Node
+	stats NodeStats
+	cost  Msat
+}
+
+var funderPolicies = []string{
+	"match",
+	"available",
+	"fixed",
+}
+
+func wrapFunderSettings(results gjson.Result) FunderSettings {
+	return FunderSettings{
+		policy:                               results.Get("policy").String(),
+		policyMod:                            results.Get("policy_mod").Int(),
+		minTheirFunding:                      msatValue(results, "min_their_funding").Sat(),
+		maxTheirFunding:                      msatValue(results, "max_their_funding").Sat(),
+		reserveTank:                          msatValue(results, "reserve_tank").Sat(),
+		fuzzPercent:                          results.Get("fuzz_percent").Int(),
+		leaseFeeBaseMsat:                     msatValue(results, "lease_fee_base"),
+		leaseFeeBasis:                        results.Get("lease_fee_basis").Int(),
+		channelFeeMaxBaseMsat:                msatValue(results, "channel_fee_max_base"),
+		channelFeeMaxProportionalThousandths: results.Get("channel_fee_max_proportional_thousandths").Int(),
+		compactLease:                         results.Get("compact_lease").String(),
+	}
+}
+
+func getFunderSettings(ui *UI) (FunderSettings, error) {
+	// funderupdate without parameters returns the current settings
+	results, err := funderUpdate(ui, map[string]interface{}{})
+	return wrapFunderSettings(results), err
+}
+
+// getLeases returns the channels where a peer bought liquidity from us.
+func getLeases(ui *UI) ([]Lease, error) {
+	var leases []Lease
+
+	info, err := getInfo(ui)
+	if err != nil {
+		return nil, err
+	}
+	localID := info.Get("id").String()
+	channels, err := listPeerChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		if channel.Get("opener").String() != "remote" {
+			continue
+		}
+		expiry := channel.Get("lease_expiry").Int()
+		fee := msatValue(channel.Result, "funding.fee_rcvd").Sat()
+		if expiry == 0 && fee == 0 {
+			continue
+		}
+		amount := msatValue(channel.Result, "funding.local_funds")
+		if amount == 0 {
+			amount = msatValue(channel.Result, "funding.local")
+		}
+		if amount == 0 {
+			amount = msatField(channel.Result, "funding_allocation_msat."+localID)
+		}
+
+		remoteNodeID := channel.peerID
+		remoteAlias := listNode(ui, remoteNodeID).alias
+		if remoteAlias == "" {
+			remoteAlias = remoteNodeID
+		}
+
+		leases = append(leases, Lease{
+			shortChannelID: channel.Get("short_channel_id").String(),
+			remoteAlias:    remoteAlias,
+			amount:         amount.Sat(),
+			fee:            fee,
+			expiry:         expiry,
+		})
+	}
+	return leases, nil
+}
+
+func (ui *UI) NewFunderForm(settings FunderSettings) *tview.Form {
+	form := tview.NewForm()
+	form.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Our liquidity ad (a) ")
+
+	initialPolicy := 0
+	for idx, policy := range funderPolicies {
+		if policy == settings.policy {
+			initialPolicy = idx
+			break
+		}
+	}
+	form.AddDropDown("Policy", funderPolicies, initialPolicy, nil)
+	form.AddInputField("Policy mod", strconv.FormatInt(settings.policyMod, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Lease fee base (msat)", strconv.FormatInt(int64(settings.leaseFeeBaseMsat), 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Lease fee basis", strconv.FormatInt(settings.leaseFeeBasis, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Channel fee max base (msat)", strconv.FormatInt(int64(settings.channelFeeMaxBaseMsat), 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Channel fee max proportional", strconv.FormatInt(settings.channelFeeMaxProportionalThousandths, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Min their funding (sats)", strconv.FormatInt(settings.minTheirFunding, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Max their funding (sats)", strconv.FormatInt(settings.maxTheirFunding, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Reserve tank (sats)", strconv.FormatInt(settings.reserveTank, 10), 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Fuzz percent", strconv.FormatInt(settings.fuzzPercent, 10), 20, tview.InputFieldInteger, nil)
+
+	form.AddButton("Update ad", func() {
+		intField := func(label string) (int64, error) {
+			field := form.GetFormItemByLabel(label).(*tview.InputField)
+			value, err := strconv.ParseInt(field.GetText(), 10, 64)
+			if err != nil {
+				ui.log.Warn(fmt.Sprintf("Incorrect %s: %s\n", label, err.Error()))
+			}
+			return value, err
+		}
+
+		_, policy := form.GetFormItemByLabel("Policy").(*tview.DropDown).GetCurrentOption()
+		params := map[string]interface{}{
+			"policy": policy,
+		}
+		fields := []struct {
+			label string
+			param string
+			unit  string
+		}{
+			{"Policy mod", "policy_mod", ""},
+			{"Lease fee base (msat)", "lease_fee_base_msat", "msat"},
+			{"Lease fee basis", "lease_fee_basis", ""},
+			{"Channel fee max base (msat)", "channel_fee_max_base_msat", "msat"},
+			{"Channel fee max proportional", "channel_fee_max_proportional_thousandths", ""},
+			{"Min their funding (sats)", "min_their_funding_msat", "sat"},
+			{"Max their funding (sats)", "max_their_funding_msat", "sat"},
+			{"Reserve tank (sats)", "reserve_tank_msat", "sat"},
+			{"Fuzz percent", "fuzz_percent", ""},
+		}
+		for _, f := range fields {
+			value, err := intField(f.label)
+			if err != nil {
+				return
+			}
+			if f.unit != "" {
+				params[f.param] = fmt.Sprintf("%d%s", value, f.unit)
+			} else {
+				params[f.param] = value
+			}
+		}
+
+		results, err := funderUpdate(ui, params)
+		if err != nil {
+			ui.log.Warn(fmt.Sprintf("Error when updating funder settings: %s\n", err))
+			return
+		}
+		ui.log.Ok("Liquidity ad updated: " + results.Get("summary").String() + "\n")
+		ui.ReloadPage("dualfunding")
+	})
+
+	return form
+}
+
+var liquiditySortOptions = []string{
+	"Total cost",
+	"Channels",
+	"Capacity",
+	"Alias",
+}
+
+// Liquidity ads comparison criteria, kept between page reloads
+var (
+	liquidityAmount       = int64(1000000)
+	liquidityMaxCost      = int64(0)
+	liquidityMaxFeeRate   = int64(0)
+	liquidityMinChannels  = int64(0)
+	selectedLiquiditySort = "Total cost"
+)
+
+// leaseCost returns the total cost of leasing amount from ad, including the
+// share of the opening transaction the seller charges for.
+func leaseCost(ad *OptionWillFund, amount Msat, feerate Feerate) Msat {
+	return ad.leaseFeeBaseMsat +
+		amount*Msat(ad.leaseFeeBasis)/10000 +
+		feerate.WeightFee(ad.fundingWeight)
+}
+
+func (ui *UI) NewLiquidityFilterForm(apply func()) *tview.Form {
+	form := tview.NewForm()
+	form.SetHorizontal(true)
+	form.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Buy liquidity (b) ")
+
+	initialSort := 0
+	for idx, option := range liquiditySortOptions {
+		if option == selectedLiquiditySort {
+			initialSort = idx
+			break
+		}
+	}
+
+	form.AddInputField("Amount (sats)", strconv.FormatInt(liquidityAmount, 10), 10, tview.InputFieldInteger, nil)
+	form.AddInputField("Max cost", strconv.FormatInt(liquidityMaxCost, 10), 8, tview.InputFieldInteger, nil)
+	form.AddInputField("Max fee prop.", strconv.FormatInt(liquidityMaxFeeRate, 10), 6, tview.InputFieldInteger, nil)
+	form.AddInputField("Min channels", strconv.FormatInt(liquidityMinChannels, 10), 5, tview.InputFieldInteger, nil)
+	form.AddDropDown("Sort", liquiditySortOptions, initialSort, nil)
+	form.AddButton("Apply", func() {
+		fields := map[string]*int64{
+			"Amount (sats)": &liquidityAmount,
+			"Max cost":      &liquidityMaxCost,
+			"Max fee prop.": &liquidityMaxFeeRate,
+			"Min channels":  &liquidityMinChannels,
+		}
+		for label, value := range fields {
+			field := form.GetFormItemByLabel(label).(*tview.InputField)
+			v, err := strconv.ParseInt(field.GetText(), 10, 64)
+			if err != nil {
+				v = 0
+			}
+			*value = v
+		}
+		_, selectedLiquiditySort = form.GetFormItemByLabel("Sort").(*tview.DropDown).GetCurrentOption()
+		apply()
+	})
+	return form
+}
+
+func sortLiquidityAds(ads []LiquidityAd) {
+	sort.SliceStable(ads, func(i, j int) bool {
+		a1 := ads[i]
+		a2 := ads[j]
+		switch selectedLiquiditySort {
+		case "Channels":
+			return a1.stats.channels > a2.stats.channels
+		case "Capacity":
+			return a1.stats.capacity > a2.stats.capacity
+		case "Alias":
+			return strings.ToLower(a1.node.alias) < strings.ToLower(a2.node.alias)
+		default:
+			return a1.cost < a2.cost
+		}
+	})
+}
+
+// getLiquidityAds returns the nodes selling liquidity and the opening fee
+// rate their cost is computed with.
+func getLiquidityAds(ui *UI) ([]LiquidityAd, Feerate, error) {
+	rates, err := getFeerates(ui)
+	if err != nil {
+		return nil, 0, err
+	}
+	feerate := FeeratePerKb(rates.Get("perkb.opening").Int())
+	stats, err := listNodeStats(ui)
+	if err != nil {
+		return nil, 0, err
+	}
+	nodes, err := listNodesThatWillFund(ui)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var ads []LiquidityAd
+	for _, node := range nodes {
+		ads = append(ads, LiquidityAd{
+			node:  node,
+			stats: stats[node.id],
+		})
+	}
+	return ads, feerate, nil
+}
+
+// visibleLiquidityAds computes the cost of every ad for the requested amount
+// and returns the ones matching the current criteria, sorted.
+func visibleLiquidityAds(ads []LiquidityAd, feerate Feerate) []LiquidityAd {
+	var visible []LiquidityAd
+	for _, ad := range ads {
+		ad.cost = leaseCost(ad.node.optionWillFund, Sats(liquidityAmount), feerate)
+		if liquidityMaxCost > 0 && ad.cost > Sats(liquidityMaxCost) {
+			continue
+		}
+		if liquidityMaxFeeRate > 0 && ad.node.optionWillFund.channelFeeMaxProportionalThousandths > liquidityMaxFeeRate {
+			continue
+		}
+		if ad.stats.channels < liquidityMinChannels {
+			continue
+		}
+		visible = append(visible, ad)
+	}
+	sortLiquidityAds(visible)
+	return visible
+}
+
+// fillLiquidityTable (re)draws the ads matching the current criteria.
+func fillLiquidityTable(t *Table, rowOffset int, ads []LiquidityAd, feerate Feerate) []LiquidityAd {
+	for row := t.GetRowCount() - 1; row >= rowOffset; row-- {
+		t.RemoveRow(row)
+	}
+
+	visible := visibleLiquidityAds(ads, feerate)
+	for idx, ad := range visible {
+		will := ad.node.optionWillFund
+		t.SetCell(idx+rowOffset, 0,
+			tview.NewTableCell("[greenyellow]"+ad.node.alias).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 1,
+			tview.NewTableCell("[yellow]"+ad.cost.Format()).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 2,
+			tview.NewTableCell(will.leaseFeeBaseMsat.Format()).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 3,
+			tview.NewTableCell(formatSats(will.leaseFeeBasis)).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 4,
+			tview.NewTableCell(formatSats(will.fundingWeight)).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 5,
+			tview.NewTableCell(will.channelFeeMaxBaseMsat.Format()).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 6,
+			tview.NewTableCell(formatSats(will.channelFeeMaxProportionalThousandths)).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 7,
+			tview.NewTableCell(formatSats(ad.stats.channels)).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 8,
+			tview.NewTableCell(formatSats(ad.stats.capacity)).SetAlign(tview.AlignRight))
+		t.SetCell(idx+rowOffset, 9,
+			tview.NewTableCell(will.compactLease).SetAlign(tview.AlignLeft))
+	}
+	t.Select(rowOffset, 0)
+	return visible
+}
+
 func dualFundingPage(ui *UI) tview.Primitive {
 
 	// Dual Funding Info
@@ -12,8 +357,10 @@ func dualFundingPage(ui *UI) tview.Primitive {
 	infoPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Dual Funding ")
 	infoPane.SetDynamicColors(true)
 
-
-	config := getConfig(ui)
+	config, err := getConfig(ui)
+	if err != nil {
+		return ui.NewErrorPage("dualfunding", err)
+	}
 	configPath := config.Get("conf").String()
 
 	dfEnabled := config.Get("experimental-dual-fund").Bool()
@@ -26,11 +373,55 @@ func dualFundingPage(ui *UI) tview.Primitive {
 	ic := NewInfoColumn("[deepskyblue]", "[white]")
 	ic.AddRow("Dual-funding enabled", dfEnabledLabel)
 
+	var funderForm *tview.Form
+	var leasesPane *tview.TextView
+
 	if dfEnabled {
+		settings, err := getFunderSettings(ui)
+		if err != nil {
+			return ui.NewErrorPage("dualfunding", err)
+		}
+		ic.AddRow("Funding policy", fmt.Sprintf("%s (%d)", settings.policy, settings.policyMod))
+		if settings.compactLease != "" {
+			ic.AddRow("Lease ID", settings.compactLease)
+		} else {
+			ic.AddRow("Lease ID", "[grey]not advertising")
+		}
+		funderForm = ui.NewFunderForm(settings)
 
+		// Leases sold
+		leasesPane = tview.NewTextView()
+		leasesPane.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Leases sold ")
+		leasesPane.SetDynamicColors(true)
+
+		leases, err := getLeases(ui)
+		if err != nil {
+			return ui.NewErrorPage("dualfunding", err)
+		}
+		totalLeased := int64(0)
+		totalFees := int64(0)
+		nextExpiry := int64(0)
+		for _, lease := range leases {
+			totalLeased += lease.amount
+			totalFees += lease.fee
+			if lease.expiry > 0 && (nextExpiry == 0 || lease.expiry < nextExpiry) {
+				nextExpiry = lease.expiry
+			}
+		}
+		lc := NewInfoColumn("[deepskyblue]", "[yellow]")
+		lc.AddRow("Leases", fmt.Sprintf("%d", len(leases)))
+		lc.AddRow("Liquidity leased (sats)", formatSats(totalLeased))
+		lc.AddRow("Lease fees earned (sats)", formatSats(totalFees))
+		if nextExpiry > 0 {
+			lc.AddRow("Next expiry (block)", fmt.Sprintf("%d", nextExpiry))
+		}
+		for _, lease := range leases {
+			lc.AddRow(lease.remoteAlias, fmt.Sprintf("%s [white]sats until block [yellow]%d", formatSats(lease.amount), lease.expiry))
+		}
+		lc.Print(leasesPane)
 	} else {
 		// Instructions
-		ic.AddRow("Instructions", "\nTo enable dual-funding add\nexperimental-dual-fund\noption to your \n" + configPath + "\nand restart c-lightning.")
+		ic.AddRow("Instructions", "\nTo enable dual-funding add\nexperimental-dual-fund\noption to your \n"+configPath+"\nand restart c-lightning.")
 	}
 	ic.Print(infoPane)
 
@@ -39,11 +430,14 @@ func dualFundingPage(ui *UI) tview.Primitive {
 	liquidityTable.SetTitle(" Liquidity Ads ")
 
 	liquidityTable.AddColumnHeader("\n[greenyellow]alias", tview.AlignRight)
-	liquidityTable.AddColumnHeader("\n[bold]lease fee base\n(sats)", tview.AlignRight)
+	liquidityTable.AddColumnHeader("\n[bold]total cost\n("+amountUnit+")", tview.AlignRight)
+	liquidityTable.AddColumnHeader("\nlease fee base\n("+amountUnit+")", tview.AlignRight)
 	liquidityTable.AddColumnHeader("\nlease fee basis", tview.AlignRight)
 	liquidityTable.AddColumnHeader("\nfunding\nweight", tview.AlignRight)
-	liquidityTable.AddColumnHeader("\nchannel fee\nmax base", tview.AlignRight)
+	liquidityTable.AddColumnHeader("\nchannel fee\nmax base ("+amountUnit+")", tview.AlignRight)
 	liquidityTable.AddColumnHeader("\nchannel fee\n max proportional", tview.AlignRight)
+	liquidityTable.AddColumnHeader("\nchannels", tview.AlignRight)
+	liquidityTable.AddColumnHeader("\ncapacity\n(sats)", tview.AlignRight)
 	liquidityTable.AddColumnHeader("\nLease ID", tview.AlignLeft)
 	liquidityTable.Separator(10)
 
@@ -62,34 +456,62 @@ func dualFundingPage(ui *UI) tview.Primitive {
 		}
 	})
 
-	ads := listNodesThatWillFund(ui)
+	ads, feerate, err := getLiquidityAds(ui)
+	if err != nil {
+		return ui.NewErrorPage("dualfunding", err)
+	}
+	var visible []LiquidityAd
 
-	for idx, ad := range ads {
-		liquidityTable.SetCell(idx+rowOffset, 0,
-			tview.NewTableCell("[greenyellow]" + ad.alias).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 1,
-			tview.NewTableCell(formatSats(ad.optionWillFund.leaseFeeBaseMsat / 1000)).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 2,
-			tview.NewTableCell(formatSats(ad.optionWillFund.leaseFeeBasis)).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 3,
-			tview.NewTableCell(formatSats(ad.optionWillFund.fundingWeight)).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 4,
-			tview.NewTableCell(formatSats(ad.optionWillFund.channelFeeMaxBaseMsat / 1000)).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 5,
-			tview.NewTableCell(formatSats(ad.optionWillFund.channelFeeMaxProportionalThousandths)).SetAlign(tview.AlignRight))
-		liquidityTable.SetCell(idx+rowOffset, 6,
-			tview.NewTableCell(ad.optionWillFund.compactLease).SetAlign(tview.AlignLeft))
+	filterForm := ui.NewLiquidityFilterForm(func() {
+		visible = fillLiquidityTable(liquidityTable, rowOffset, ads, feerate)
+		ui.app.SetFocus(liquidityTable)
+	})
 
+	// Keyboard handler
+	liquidityTable.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'a':
+			if funderForm != nil {
+				ui.app.SetFocus(funderForm)
+			}
+		case 'b':
+			ui.app.SetFocus(filterForm)
+		}
+		return event
+	})
+	if funderForm != nil {
+		funderForm.SetCancelFunc(func() {
+			ui.app.SetFocus(liquidityTable)
+		})
 	}
+	filterForm.SetCancelFunc(func() {
+		ui.app.SetFocus(liquidityTable)
+	})
+
+	visible = fillLiquidityTable(liquidityTable, rowOffset, ads, feerate)
+	liquidityTable.SetExport(ui, "liquidity-ads", func() Export {
+		return liquidityAdsExport(visible)
+	})
+
+	dashRight := tview.NewFlex()
+	dashRight.SetDirection(tview.FlexRow)
+	dashRight.AddItem(filterForm, 5, 0, false)
+	dashRight.AddItem(liquidityTable, 0, 1, true)
 
 	dash := tview.NewFlex()
 	dashLeft := tview.NewFlex()
 
 	dashLeft.SetDirection(tview.FlexRow)
-	dashLeft.AddItem(infoPane, 0, 2, false)
+	if funderForm != nil {
+		dashLeft.AddItem(infoPane, 8, 0, false)
+		dashLeft.AddItem(funderForm, 0, 3, false)
+		dashLeft.AddItem(leasesPane, 0, 1, false)
+	} else {
+		dashLeft.AddItem(infoPane, 0, 2, false)
+	}
 
 	dash.AddItem(dashLeft, 0, 1, false)
-	dash.AddItem(liquidityTable, 0, 1, true)
+	dash.AddItem(dashRight, 0, 1, true)
 	return dash
 
-}
\ No newline at end of file
+}
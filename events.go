@@ -0,0 +1,116 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// Types of the events published on the event bus.
+const (
+	EventChannelOpened    = "channel_opened"
+	EventChannelClosed    = "channel_closed"
+	EventChannelInactive  = "channel_inactive"
+	EventPeerDisconnected = "peer_disconnected"
+	EventForwardSettled   = "forward_settled"
+	EventInvoicePaid      = "invoice_paid"
+	EventFundsConfirmed   = "funds_confirmed"
+	EventFeeChanged       = "fee_changed"
+	EventPaymentFailed    = "payment_failed"
+	EventTest             = "test"
+)
+
+// warningEvents are the events about something going wrong.
+var warningEvents = map[string]bool{
+	EventChannelClosed:    true,
+	EventChannelInactive:  true,
+	EventPeerDisconnected: true,
+	EventPaymentFailed:    true,
+}
+
+// eventTypes are the types hooks can be set on.
+var eventTypes = []string{
+	EventChannelOpened,
+	EventChannelClosed,
+	EventChannelInactive,
+	EventPeerDisconnected,
+	EventForwardSettled,
+	EventInvoicePaid,
+	EventFundsConfirmed,
+	EventFeeChanged,
+	EventPaymentFailed,
+}
+
+// Event is something that happened on the node.
+type Event struct {
+	Type    string                 `json:"type"`
+	Time    time.Time              `json:"time"`
+	Title   string                 `json:"title"`
+	Message string                 `json:"message"`
+	Data    map[string]interface{} `json:"data,omitempty"`
+}
+
+func NewEvent(eventType, title, message string, data map[string]interface{}) Event {
+	return Event{eventType, time.Now(), title, message, data}
+}
+
+func (e Event) Warning() bool {
+	return warningEvents[e.Type]
+}
+
+// EventBus hands the events published to every subscriber. Each subscriber
+// gets them in order on its own goroutine, so a slow one, such as a webhook,
+// doesn't hold up the others, and publishing never waits, even from the UI's
+// event loop that the toasts wait for.
+type EventBus struct {
+	subscribers []*subscriber
+	lock        sync.Mutex
+}
+
+type subscriber struct {
+	handler func(Event)
+	queue   []Event
+	wake    chan struct{}
+	lock    sync.Mutex
+}
+
+func (b *EventBus) Subscribe(handler func(Event)) {
+	s := &subscriber{handler: handler, wake: make(chan struct{}, 1)}
+	go s.run()
+
+	b.lock.Lock()
+	b.subscribers = append(b.subscribers, s)
+	b.lock.Unlock()
+}
+
+func (b *EventBus) Publish(e Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for _, s := range b.subscribers {
+		s.lock.Lock()
+		s.queue = append(s.queue, e)
+		s.lock.Unlock()
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (s *subscriber) run() {
+	for range s.wake {
+		for {
+			s.lock.Lock()
+			if len(s.queue) == 0 {
+				s.lock.Unlock()
+				break
+			}
+			e := s.queue[0]
+			s.queue = s.queue[1:]
+			s.lock.Unlock()
+			s.handler(e)
+		}
+	}
+}
+
+var events = &EventBus{}
@@ -0,0 +1,431 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"github.com/rivo/tview"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Export holds the typed rows behind a table. Totals has one value per
+// column, nil where the column has no total.
+type Export struct {
+	Columns []string
+	Rows    [][]interface{}
+	Totals  []interface{}
+	// formats other than csv and json the rows can be written in
+	writers map[string]func(w io.Writer) error
+}
+
+// Formats lists the formats e can be written in.
+func (e Export) Formats() []string {
+	formats := []string{"csv", "json"}
+	var extra []string
+	for format := range e.writers {
+		extra = append(extra, format)
+	}
+	sort.Strings(extra)
+	return append(formats, extra...)
+}
+
+var colorTagRe = regexp.MustCompile(`\[[a-zA-Z0-9#:-]*\]`)
+
+// stripColors removes the tview color tags from s.
+func stripColors(s string) string {
+	return colorTagRe.ReplaceAllString(s, "")
+}
+
+func formatExportValue(v interface{}) string {
+	switch value := v.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		if value.IsZero() {
+			return ""
+		}
+		return value.UTC().Format(time.RFC3339)
+	case []string:
+		return strings.Join(value, " ")
+	}
+	return fmt.Sprint(v)
+}
+
+func (e Export) WriteCSV(w io.Writer) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(e.Columns); err != nil {
+		return err
+	}
+	records := e.Rows
+	if e.Totals != nil {
+		records = append(records[:len(records):len(records)], e.Totals)
+	}
+	for idx, row := range records {
+		record := make([]string, len(row))
+		for i, v := range row {
+			record[i] = formatExportValue(v)
+		}
+		if e.Totals != nil && idx == len(records)-1 && record[0] == "" {
+			record[0] = "total"
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func (e Export) WriteJSON(w io.Writer) error {
+	object := func(row []interface{}) map[string]interface{} {
+		o := make(map[string]interface{})
+		for i, v := range row {
+			if t, ok := v.(time.Time); ok {
+				v = formatExportValue(t)
+			}
+			o[e.Columns[i]] = v
+		}
+		return o
+	}
+	rows := make([]map[string]interface{}, 0, len(e.Rows))
+	for _, row := range e.Rows {
+		rows = append(rows, object(row))
+	}
+	doc := map[string]interface{}{"rows": rows}
+	if e.Totals != nil {
+		totals := make(map[string]interface{})
+		for i, v := range e.Totals {
+			if v != nil {
+				totals[e.Columns[i]] = v
+			}
+		}
+		doc["totals"] = totals
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(doc)
+}
+
+// Write writes e to w as csv or json.
+func (e Export) Write(w io.Writer, format string) error {
+	if writer, exists := e.writers[format]; exists {
+		return writer(w)
+	}
+	switch format {
+	case "csv":
+		return e.WriteCSV(w)
+	case "json":
+		return e.WriteJSON(w)
+	}
+	return fmt.Errorf("unknown export format %q", format)
+}
+
+func exportExtension(format string) string {
+	if format == "json" {
+		return ".json"
+	}
+	return ".csv"
+}
+
+// exportFormat guesses the format from the extension of path.
+func exportFormat(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".json") {
+		return "json"
+	}
+	return "csv"
+}
+
+func writeExportFile(path, format string, e Export) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := e.Write(f, format); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func channelsExport(channels []Channel) Export {
+	e := Export{Columns: []string{
+		"short_channel_id", "node_id", "alias", "state", "connected", "opener",
+		"private", "capacity_msat", "local_balance_msat", "remote_balance_msat",
+		"local_base_fee_msat", "local_fee_rate_ppm", "remote_base_fee_msat",
+		"remote_fee_rate_ppm", "last_forward", "local_fees_earned_msat",
+		"remote_fees_earned_msat", "age_blocks", "reliability",
+	}}
+	var capacity, local, remote, localFees, remoteFees Msat
+	for _, c := range channels {
+		var lastForward time.Time
+		if c.lastForward > 0 {
+			lastForward = time.Unix(int64(c.lastForward), 0)
+		}
+		var reliability interface{}
+		if c.reliability.score >= 0 {
+			reliability = c.reliability.score
+		}
+		e.Rows = append(e.Rows, []interface{}{
+			c.shortChannelID, c.remoteNodeID, c.remoteAlias, c.state, c.peerConnected, c.opener,
+			c.private, c.capacity, c.localBalance, c.remoteBalance,
+			c.localBaseFee, c.localFeeRate, c.remoteBaseFee,
+			c.remoteFeeRate, lastForward, c.localFees,
+			c.remoteFees, c.age, reliability,
+		})
+		capacity += c.capacity
+		local += c.localBalance
+		remote += c.remoteBalance
+		localFees += c.localFees
+		remoteFees += c.remoteFees
+	}
+	e.Totals = make([]interface{}, len(e.Columns))
+	e.Totals[7] = capacity
+	e.Totals[8] = local
+	e.Totals[9] = remote
+	e.Totals[15] = localFees
+	e.Totals[16] = remoteFees
+	return e
+}
+
+func activityExport(activities []*Activity) Export {
+	e := Export{Columns: []string{"date", "operation", "amount_msat", "fees_msat", "description"}}
+	var fees Msat
+	for _, a := range activities {
+		e.Rows = append(e.Rows, []interface{}{
+			a.date, stripColors(a.operation), a.amount, a.fees, strings.TrimSpace(a.description),
+		})
+		fees += a.fees
+	}
+	e.Totals = []interface{}{nil, nil, nil, fees, nil}
+	return e
+}
+
+func liquidityAdsExport(ads []LiquidityAd) Export {
+	e := Export{Columns: []string{
+		"node_id", "alias", "total_cost_msat", "lease_fee_base_msat", "lease_fee_basis",
+		"funding_weight", "channel_fee_max_base_msat", "channel_fee_max_proportional_thousandths",
+		"channels", "capacity_sat", "lease_id",
+	}}
+	for _, ad := range ads {
+		will := ad.node.optionWillFund
+		e.Rows = append(e.Rows, []interface{}{
+			ad.node.id, ad.node.alias, ad.cost, will.leaseFeeBaseMsat, will.leaseFeeBasis,
+			will.fundingWeight, will.channelFeeMaxBaseMsat, will.channelFeeMaxProportionalThousandths,
+			ad.stats.channels, ad.stats.capacity, will.compactLease,
+		})
+	}
+	return e
+}
+
+func peersExport(peers []Peer) Export {
+	e := Export{Columns: []string{"node_id", "alias", "connected", "channels", "latency_ms", "addresses", "features"}}
+	for _, p := range peers {
+		var latency interface{}
+		if l, exists := getPeerLatency(p.id); exists {
+			latency = l.Milliseconds()
+		}
+		e.Rows = append(e.Rows, []interface{}{
+			p.id, p.alias, p.connected, p.channels, latency, p.netaddr, decodeFeatures(p.features),
+		})
+	}
+	return e
+}
+
+func networkExport(nodes []*GraphNode) Export {
+	e := Export{Columns: []string{
+		"node_id", "alias", "capacity_sat", "channels", "median_fee_rate_ppm",
+		"centrality", "distance_hops", "reachability_gain", "peer",
+	}}
+	for _, gn := range nodes {
+		var distance interface{}
+		if gn.distance >= 0 {
+			distance = gn.distance
+		}
+		e.Rows = append(e.Rows, []interface{}{
+			gn.node.id, gn.node.alias, gn.capacity, gn.channels, gn.medianFee,
+			gn.centrality, distance, gn.reachGain, gn.peer,
+		})
+	}
+	return e
+}
+
+func pendingExport(channels []PendingChannel) Export {
+	e := Export{Columns: []string{
+		"short_channel_id", "funding_txid", "alias", "state", "capacity_sat",
+		"local_balance_sat", "confirmations", "spendable_in_blocks", "unresolved_outputs", "status",
+	}}
+	for _, c := range channels {
+		var spendableIn interface{}
+		if c.spendableIn >= 0 {
+			spendableIn = c.spendableIn
+		}
+		e.Rows = append(e.Rows, []interface{}{
+			c.shortChannelID, c.fundingTxid, c.remoteAlias, c.state, c.capacity,
+			c.localBalance, c.confirmations, spendableIn, c.unresolved, c.status,
+		})
+	}
+	return e
+}
+
+func closedExport(channels []ClosedChannel) Export {
+	e := Export{Columns: []string{
+		"short_channel_id", "alias", "close_cause", "opener", "closer",
+		"capacity_sat", "final_balance_sat", "onchain_fees_sat",
+	}}
+	var fees int64
+	for _, c := range channels {
+		e.Rows = append(e.Rows, []interface{}{
+			c.shortChannelID, c.remoteAlias, c.closeCause, c.opener, c.closer,
+			c.capacity, c.finalBalance, c.onChainFees,
+		})
+		fees += c.onChainFees
+	}
+	e.Totals = make([]interface{}, len(e.Columns))
+	e.Totals[7] = fees
+	return e
+}
+
+func recommendationsExport(recommendations []Recommendation) Export {
+	e := Export{Columns: []string{
+		"short_channel_id", "alias", "health", "action", "suggested_fee_rate_ppm",
+		"idle_days", "yield_ppm_year", "outbound_share", "uptime_7d",
+		"local_fee_rate_ppm", "others_fee_rate_ppm", "age_days", "reasons",
+	}}
+	for _, r := range recommendations {
+		e.Rows = append(e.Rows, []interface{}{
+			r.channel.shortChannelID, r.channel.remoteAlias, r.health, r.action, r.feeRate,
+			r.idleDays, r.yield, r.balance, r.channel.reliability.uptime7d,
+			r.channel.localFeeRate, r.inboundFee, r.ageDays, strings.Join(r.reasons, ", "),
+		})
+	}
+	return e
+}
+
+// exporters build the exports available from the command line.
+var exporters = map[string]func(ui *UI) (Export, error){
+	"channels": func(ui *UI) (Export, error) {
+		channels, err := getChannels(ui)
+		return channelsExport(filterChannels(channels)), err
+	},
+	"activity": func(ui *UI) (Export, error) {
+		info, err := getInfo(ui)
+		if err != nil {
+			return Export{}, err
+		}
+		activities, err := getActivities(ui, info.Get("id").String(), time.Now().Add(-activityPeriod))
+		return activityExport(activities), err
+	},
+	"liquidity-ads": func(ui *UI) (Export, error) {
+		ads, feerate, err := getLiquidityAds(ui)
+		return liquidityAdsExport(visibleLiquidityAds(ads, feerate)), err
+	},
+	"peers": func(ui *UI) (Export, error) {
+		peers, err := getPeers(ui)
+		return peersExport(peers), err
+	},
+	"network": func(ui *UI) (Export, error) {
+		graph, err := getGraph(ui)
+		if err != nil {
+			return Export{}, err
+		}
+		return networkExport(visibleGraphNodes(graph)), nil
+	},
+	"pending": func(ui *UI) (Export, error) {
+		channels, err := getPendingChannels(ui)
+		return pendingExport(channels), err
+	},
+	"closed": func(ui *UI) (Export, error) {
+		channels, err := getClosedChannels(ui)
+		return closedExport(channels), err
+	},
+	"recommendations": func(ui *UI) (Export, error) {
+		recommendations, err := getRecommendations(ui)
+		return recommendationsExport(recommendations), err
+	},
+	"ledger": func(ui *UI) (Export, error) {
+		ledger, err := getLedger(ui)
+		return ledgerExport(ledger), err
+	},
+}
+
+func exporterNames() []string {
+	var names []string
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Export writes the table name to path, or to stdout when path is empty.
+func (ui *UI) Export(name, path, format string) error {
+	exporter, exists := exporters[name]
+	if !exists {
+		return fmt.Errorf("unknown table %q, expected one of %s", name, strings.Join(exporterNames(), ", "))
+	}
+	if format == "" {
+		format = exportFormat(path)
+	}
+	e, err := exporter(ui)
+	if err != nil {
+		return err
+	}
+	if path == "" {
+		return e.Write(os.Stdout, format)
+	}
+	return writeExportFile(path, format, e)
+}
+
+// NewExportPage asks where to write e and gives the focus back to parent.
+func (ui *UI) NewExportPage(name string, e Export, parent tview.Primitive) tview.Primitive {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Export " + name + " ")
+	form.SetBorderColor(BorderColor)
+
+	formats := e.Formats()
+	defaultPath := func(format string) string {
+		return filepath.Join(ui.dataDir, "exports", name+"-"+time.Now().Format("20060102-150405")+"."+format)
+	}
+
+	form.AddInputField("Path", defaultPath("csv"), 60, nil, nil)
+	form.AddDropDown("Format", formats, 0, func(option string, optionIndex int) {
+		if form.GetFormItemCount() < 2 {
+			return
+		}
+		// follow the format in the file extension
+		field := form.GetFormItemByLabel("Path").(*tview.InputField)
+		path := field.GetText()
+		if ext := filepath.Ext(path); ext == ".csv" || ext == ".json" {
+			field.SetText(strings.TrimSuffix(path, ext) + exportExtension(option))
+		}
+	})
+
+	done := func() {
+		ui.DeletePage("export")
+		ui.app.SetFocus(parent)
+	}
+	form.AddButton("Export", func() {
+		path := form.GetFormItemByLabel("Path").(*tview.InputField).GetText()
+		_, format := form.GetFormItemByLabel("Format").(*tview.DropDown).GetCurrentOption()
+		if err := writeExportFile(path, format, e); err != nil {
+			ui.log.Warn("Export failed: " + err.Error() + "\n")
+			return
+		}
+		ui.log.Ok(fmt.Sprintf("Exported %d rows to %s\n", len(e.Rows), path))
+		done()
+	})
+	form.AddButton("Cancel", done)
+	form.SetCancelFunc(done)
+
+	return ui.Modal(form, 80, 9)
+}
@@ -0,0 +1,289 @@
+package main
+
+import (
+	"bytes"
+	"github.com/rivo/tview"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// schemas the fixtures are checked against, oldest first
+var testSchemas = []string{"old", "new"}
+
+// startFakeNodeUI serves the fixtures of schema to a UI keeping its data in
+// a temporary directory. The caches of the node of a previous test are
+// cleared, they would be served instead.
+func startFakeNodeUI(t *testing.T, schema string) *UI {
+	t.Helper()
+	ln = nil
+	nodeVersion = nil
+	NodeCache = make(map[string]Node)
+	lastCacheLookup = time.Time{}
+	NodeStatsCache = make(map[string]NodeStats)
+	lastStatsLookup = time.Time{}
+	graphCache = nil
+	feeHistory = nil
+	feeHistoryOnce = sync.Once{}
+	liquidityEstimates = nil
+	settings = nil
+
+	dir := t.TempDir()
+	node, err := StartFakeNode(schema, filepath.Join(dir, "lightning-rpc"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(node.Close)
+
+	metrics := NewMetricStore(filepath.Join(dir, "metrics"), 24*time.Hour, time.Hour, time.Hour)
+	if _, err := metrics.Open(); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(metrics.Close)
+	return &UI{
+		tview.NewApplication(),
+		tview.NewPages(),
+		make(map[string]tview.Primitive),
+		nil,
+		NewConsoleLog(ioutil.Discard),
+		node.path,
+		dir,
+		metrics,
+	}
+}
+
+func TestFakeNodeChannels(t *testing.T) {
+	want := map[string]Channel{
+		"781500x340x0": {
+			remoteAlias:   "carol",
+			opener:        "remote",
+			capacity:      Sats(5000000),
+			localBalance:  Sats(1000000),
+			remoteBalance: Sats(4000000),
+			commitFee:     Sats(183),
+			localBaseFee:  500,
+			localFeeRate:  250,
+			remoteBaseFee: 1000,
+			remoteFeeRate: 10,
+			// fee of the forward out to bob
+			localFees: 25500,
+			// 1000 msat + 10 ppm of the 250026000 msat forward in
+			remoteFees:    3500,
+			peerConnected: true,
+			age:           18500,
+		},
+		"780000x1200x1": {
+			remoteAlias:   "bob",
+			opener:        "local",
+			capacity:      Sats(2000000),
+			localBalance:  Sats(1200000),
+			remoteBalance: Sats(800000),
+			commitFee:     Sats(183),
+			localBaseFee:  1000,
+			localFeeRate:  100,
+			remoteBaseFee: 0,
+			remoteFeeRate: 50,
+			localFees:     26000,
+			// 50 ppm of 100025500 msat, the fraction of a sat kept
+			remoteFees:    5001,
+			peerConnected: true,
+			age:           20000,
+		},
+		"790100x88x2": {
+			remoteAlias:   "dave",
+			opener:        "local",
+			capacity:      Sats(1000000),
+			localBalance:  Sats(990000),
+			remoteBalance: Sats(10000),
+			commitFee:     Sats(183),
+			localBaseFee:  0,
+			localFeeRate:  1,
+			remoteBaseFee: 1,
+			remoteFeeRate: 1,
+			private:       true,
+			age:           9900,
+		},
+	}
+	for _, schema := range testSchemas {
+		t.Run(schema, func(t *testing.T) {
+			ui := startFakeNodeUI(t, schema)
+			channels, err := getChannels(ui)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(channels) != len(want) {
+				t.Fatalf("%d channels, expected %d", len(channels), len(want))
+			}
+			for _, c := range channels {
+				w, exists := want[c.shortChannelID]
+				if !exists {
+					t.Errorf("unexpected channel %s", c.shortChannelID)
+					continue
+				}
+				got := Channel{
+					remoteAlias:   c.remoteAlias,
+					opener:        c.opener,
+					capacity:      c.capacity,
+					localBalance:  c.localBalance,
+					remoteBalance: c.remoteBalance,
+					commitFee:     c.commitFee,
+					localBaseFee:  c.localBaseFee,
+					localFeeRate:  c.localFeeRate,
+					remoteBaseFee: c.remoteBaseFee,
+					remoteFeeRate: c.remoteFeeRate,
+					localFees:     c.localFees,
+					remoteFees:    c.remoteFees,
+					private:       c.private,
+					peerConnected: c.peerConnected,
+					age:           c.age,
+				}
+				if got != w {
+					t.Errorf("channel %s\ngot      %+v\nexpected %+v", c.shortChannelID, got, w)
+				}
+			}
+		})
+	}
+}
+
+func TestFakeNodeActivities(t *testing.T) {
+	type activity struct {
+		date        int64
+		amount      Msat
+		fees        Msat
+		operation   string
+		description string
+	}
+	want := []activity{
+		{1690018000, 2100000, 210, "[darkviolet]keysend to carol", " thanks for the episode"},
+		{1690010800, 5000000, 1005, "[darkviolet]sent to carol", " "},
+		{1690007200, 1000000, 0, "[green]received", " coffee"},
+	}
+	// the fixtures are from July 2023
+	since := time.Date(2023, 7, 1, 0, 0, 0, 0, time.UTC)
+	for _, schema := range testSchemas {
+		t.Run(schema, func(t *testing.T) {
+			ui := startFakeNodeUI(t, schema)
+			info, err := getInfo(ui)
+			if err != nil {
+				t.Fatal(err)
+			}
+			activities, err := getActivities(ui, info.Get("id").String(), since)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(activities) != len(want) {
+				t.Fatalf("%d activities, expected %d", len(activities), len(want))
+			}
+			for i, a := range activities {
+				got := activity{a.date.Unix(), a.amount, a.fees, a.operation, a.description}
+				if got != want[i] {
+					t.Errorf("activity %d\ngot      %+v\nexpected %+v", i, got, want[i])
+				}
+			}
+
+			// nothing in the last month
+			recent, err := getActivities(ui, info.Get("id").String(), time.Now().Add(-activityPeriod))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(recent) != 0 {
+				t.Errorf("%d activities in the last month, expected none", len(recent))
+			}
+		})
+	}
+}
+
+func TestFakeNodeLedger(t *testing.T) {
+	type entry struct {
+		time   int64
+		kind   string
+		amount Msat
+		fee    Msat
+	}
+	lightning := []entry{
+		{1690007200, LedgerInvoiceReceived, 1000000, 0},
+		{1690010802, LedgerPaymentSent, -5000000, 1005},
+		{1690018001, LedgerPaymentSent, -2100000, 210},
+		{1690086401, LedgerRoutingFee, 26000, 0},
+		{1690172801, LedgerRoutingFee, 25500, 0},
+	}
+	want := map[string][]entry{
+		"old": lightning,
+		// with the on-chain movements of the bookkeeper plugin
+		"new": append([]entry{
+			{1689913600, LedgerDeposit, 150000000, 0},
+			{1689996400, LedgerOnchainFee, 0, 2051000},
+		}, lightning...),
+	}
+	for _, schema := range testSchemas {
+		t.Run(schema, func(t *testing.T) {
+			ui := startFakeNodeUI(t, schema)
+			var logged bytes.Buffer
+			ui.log = NewConsoleLog(&logged)
+			ledger, err := getLedger(ui)
+			if err != nil {
+				t.Fatal(err)
+			}
+			ui.log.Close()
+			// the old node has no bookkeeper plugin
+			warned := strings.Contains(logged.String(), "bookkeeper plugin isn't running")
+			if warned != (schema == "old") {
+				t.Errorf("warned about the missing on-chain entries: %v, log %q", warned, logged.String())
+			}
+			if len(ledger) != len(want[schema]) {
+				t.Fatalf("%d ledger entries, expected %d", len(ledger), len(want[schema]))
+			}
+			for i, e := range ledger {
+				got := entry{e.time.Unix(), e.kind, e.amount, e.fee}
+				if got != want[schema][i] {
+					t.Errorf("entry %d\ngot      %+v\nexpected %+v", i, got, want[schema][i])
+				}
+			}
+		})
+	}
+}
+
+func TestFakeNodeSummary(t *testing.T) {
+	for _, schema := range testSchemas {
+		t.Run(schema, func(t *testing.T) {
+			ui := startFakeNodeUI(t, schema)
+			s, err := getNodeSummary(ui)
+			if err != nil {
+				t.Fatal(err)
+			}
+			checks := []struct {
+				name      string
+				got, want interface{}
+			}{
+				{"alias", s.Alias, "FAKENODE"},
+				{"network", s.Network, "bitcoin"},
+				{"blockheight", s.Blockheight, int64(800000)},
+				{"peers", s.Peers, int64(3)},
+				{"active channels", s.ActiveChannels, int64(2)},
+				{"inactive channels", s.InactiveChannels, int64(1)},
+				{"pending channels", s.PendingChannels, int64(1)},
+				{"large channels", s.LargeChannels, true},
+				{"min capacity", s.MinCapacity, int64(10000)},
+				{"fees collected", s.FeesCollected, int64(1234)},
+				{"on-chain funds", s.OnChainFunds, int64(150000)},
+				{"UTXOs", s.UTXOs, int64(1)},
+				{"outbound", s.Outbound, int64(6190000)},
+				{"inbound", s.Inbound, int64(4810000)},
+				{"smallest channel", s.SmallestChannel, int64(1000000)},
+				{"biggest channel", s.BiggestChannel, int64(5000000)},
+				{"default base fee", s.DefaultBaseFee, int64(1000)},
+				{"default fee rate", s.DefaultFeeRate, int64(10)},
+				{"opening feerate", s.Feerates["opening"], FeeratePerKb(20000)},
+				{"min acceptable feerate", s.Feerates["min_acceptable"], FeeratePerKw(253)},
+			}
+			for _, check := range checks {
+				if check.got != check.want {
+					t.Errorf("%s %v, expected %v", check.name, check.got, check.want)
+				}
+			}
+		})
+	}
+}
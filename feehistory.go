@@ -0,0 +1,143 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"sort"
+	"sync"
+)
+
+// FeeSnapshot is the fee a node charged on its side of a channel, as
+// announced in gossip at time (unix seconds).
+type FeeSnapshot struct {
+	Time           int64  `json:"time"`
+	ShortChannelID string `json:"scid"`
+	Source         string `json:"source"`
+	Base           int64  `json:"base"`
+	Rate           int64  `json:"ppm"`
+}
+
+type FeeHistory struct {
+	path      string
+	snapshots map[string][]FeeSnapshot
+	// pages loading in the background record gossip while the UI reads
+	lock sync.Mutex
+}
+
+var feeHistory *FeeHistory
+var feeHistoryOnce sync.Once
+
+func feeHistoryKey(shortChannelID, source string) string {
+	return shortChannelID + "/" + source
+}
+
+// getFeeHistory loads the fee snapshots recorded so far, the first time.
+func getFeeHistory(ui *UI) *FeeHistory {
+	feeHistoryOnce.Do(func() {
+		feeHistory = loadFeeHistory(ui)
+	})
+	return feeHistory
+}
+
+func loadFeeHistory(ui *UI) *FeeHistory {
+	h := &FeeHistory{
+		path:      ui.dataPath("fees.jsonl"),
+		snapshots: make(map[string][]FeeSnapshot),
+	}
+	corrupt := 0
+	err := readJSONLines(h.path, func(line []byte) error {
+		var snapshot FeeSnapshot
+		if err := json.Unmarshal(line, &snapshot); err != nil {
+			corrupt += 1
+			return nil
+		}
+		key := feeHistoryKey(snapshot.ShortChannelID, snapshot.Source)
+		h.snapshots[key] = append(h.snapshots[key], snapshot)
+		return nil
+	})
+	if err != nil {
+		ui.log.Warn("Can't read fee history " + h.path + ": " + err.Error() + "\n")
+	}
+	if corrupt > 0 {
+		ui.log.Warn(fmt.Sprintf("Skipped %d corrupt lines of fee history %s\n", corrupt, h.path))
+	}
+	for _, snapshots := range h.snapshots {
+		sort.SliceStable(snapshots, func(i, j int) bool {
+			return snapshots[i].Time < snapshots[j].Time
+		})
+	}
+	return h
+}
+
+// Record stores the snapshots whose fee changed since the last one of their
+// channel side.
+func (h *FeeHistory) Record(ui *UI, snapshots ...FeeSnapshot) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var changed []interface{}
+	for _, snapshot := range snapshots {
+		key := feeHistoryKey(snapshot.ShortChannelID, snapshot.Source)
+		history := h.snapshots[key]
+		if len(history) > 0 {
+			last := history[len(history)-1]
+			if last.Time >= snapshot.Time || (last.Base == snapshot.Base && last.Rate == snapshot.Rate) {
+				continue
+			}
+		}
+		// newer than the last one, the history stays sorted
+		h.snapshots[key] = append(history, snapshot)
+		changed = append(changed, snapshot)
+	}
+	if len(changed) == 0 {
+		return
+	}
+	if err := appendJSONLines(h.path, changed...); err != nil {
+		ui.log.Warn("Can't write fee history " + h.path + ": " + err.Error() + "\n")
+	}
+}
+
+// RecordGossip stores the fees of every channel side of a listchannels
+// reply.
+func (h *FeeHistory) RecordGossip(ui *UI, channels gjson.Result) {
+	var snapshots []FeeSnapshot
+	for _, side := range channels.Get("channels").Array() {
+		fee := gossipFee(side)
+		snapshots = append(snapshots, FeeSnapshot{
+			Time:           side.Get("last_update").Int(),
+			ShortChannelID: side.Get("short_channel_id").String(),
+			Source:         side.Get("source").String(),
+			Base:           fee.base,
+			Rate:           fee.rate,
+		})
+	}
+	h.Record(ui, snapshots...)
+}
+
+// History returns the fee snapshots of one side of a channel, oldest first.
+func (h *FeeHistory) History(shortChannelID, source string) []FeeSnapshot {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	history := h.snapshots[feeHistoryKey(shortChannelID, source)]
+	return append([]FeeSnapshot(nil), history...)
+}
+
+// FeeAt returns the fee in effect at time t. Before the first snapshot the
+// oldest known fee is used.
+func (h *FeeHistory) FeeAt(shortChannelID, source string, t int64) (Fee, bool) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	history := h.snapshots[feeHistoryKey(shortChannelID, source)]
+	if len(history) == 0 {
+		return Fee{}, false
+	}
+	current := history[0]
+	for _, snapshot := range history {
+		if snapshot.Time > t {
+			break
+		}
+		current = snapshot
+	}
+	return Fee{current.Base, current.Rate}, true
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/tidwall/gjson"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PriceProvider gives the price of a bitcoin in a fiat currency.
+type PriceProvider interface {
+	// Price returns the price at t. Providers without history return the
+	// current price.
+	Price(t time.Time) (float64, error)
+	Currency() string
+}
+
+// priceProvider is nil unless the user configured a price source
+var priceProvider PriceProvider
+
+// StaticPriceProvider always returns the price given in the configuration.
+type StaticPriceProvider struct {
+	currency string
+	price    float64
+}
+
+func NewStaticPriceProvider(currency string, price float64) *StaticPriceProvider {
+	return &StaticPriceProvider{currency, price}
+}
+
+func (p *StaticPriceProvider) Price(t time.Time) (float64, error) {
+	return p.price, nil
+}
+
+func (p *StaticPriceProvider) Currency() string {
+	return p.currency
+}
+
+// priceRefresh is how often --price-url is fetched, set with
+// --price-refresh.
+var priceRefresh = 5 * time.Minute
+
+// how long a fetched price is shown while fetching it again fails
+const priceStaleAfter = 15 * time.Minute
+
+// longest wait between fetches after failures
+const maxPriceBackoff = 10 * time.Minute
+
+var errPriceNotFetched = errors.New("the price is still being fetched")
+
+// HTTPPriceProvider reads the current price from a JSON document served at
+// url, at the gjson path. Once started, the price is fetched again every
+// priceRefresh in the background, backing off while fetching fails, so Price
+// never waits on the network. It has no history, Price ignores t.
+type HTTPPriceProvider struct {
+	url      string
+	path     string
+	currency string
+	client   *http.Client
+	mu       sync.Mutex
+	price    float64
+	fetched  time.Time
+	err      error
+	start    sync.Once
+}
+
+func NewHTTPPriceProvider(url, path, currency string) *HTTPPriceProvider {
+	return &HTTPPriceProvider{
+		url:      url,
+		path:     path,
+		currency: currency,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Start fetches the price in the background until the program exits.
+func (p *HTTPPriceProvider) Start() {
+	p.start.Do(func() {
+		go p.run()
+	})
+}
+
+func (p *HTTPPriceProvider) run() {
+	backoff := priceRefresh / 8
+	for {
+		price, err := p.fetch()
+		p.mu.Lock()
+		p.err = err
+		if err == nil {
+			p.price = price
+			p.fetched = time.Now()
+		}
+		p.mu.Unlock()
+
+		if err == nil {
+			backoff = priceRefresh / 8
+			time.Sleep(priceRefresh)
+			continue
+		}
+		time.Sleep(backoff)
+		if backoff *= 2; backoff > maxPriceBackoff {
+			backoff = maxPriceBackoff
+		}
+	}
+}
+
+func (p *HTTPPriceProvider) fetch() (float64, error) {
+	resp, err := p.client.Get(p.url)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("%s: %s", p.url, resp.Status)
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return 0, err
+	}
+	value := gjson.GetBytes(body, p.path)
+	if !value.Exists() || value.Float() <= 0 {
+		return 0, fmt.Errorf("%s: no price at %q", p.url, p.path)
+	}
+	return value.Float(), nil
+}
+
+// Price returns the last price fetched, whatever t is. A price older than
+// priceStaleAfter gives way to the error fetching it again.
+func (p *HTTPPriceProvider) Price(t time.Time) (float64, error) {
+	p.Start()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.fetched.IsZero() && (p.err == nil || time.Since(p.fetched) < priceStaleAfter) {
+		return p.price, nil
+	}
+	if p.err != nil {
+		return 0, p.err
+	}
+	return 0, errPriceNotFetched
+}
+
+func (p *HTTPPriceProvider) Currency() string {
+	return p.currency
+}
+
+// fiatValue converts msat to fiat at the price provider gives for t.
+func fiatValue(provider PriceProvider, msat Msat, t time.Time) (float64, bool) {
+	if provider == nil {
+		return 0, false
+	}
+	price, err := provider.Price(t)
+	if err != nil {
+		return 0, false
+	}
+	return float64(msat) / float64(MsatPerBTC) * price, true
+}
+
+var currencySymbols = map[string]string{
+	"USD": "$",
+	"EUR": "€",
+	"GBP": "£",
+	"JPY": "¥",
+}
+
+// historicalPriceProvider returns the price source for past entries, none
+// unless it knows the price over time: a fixed or current price would value
+// old entries at today's price.
+func historicalPriceProvider() PriceProvider {
+	if history, exists := priceProvider.(*PriceHistory); exists {
+		return history
+	}
+	return nil
+}
+
+// formatFiatAmount formats amount in the configured currency at the price of
+// t, or returns an empty string when there is no price.
+func formatFiatAmount(amount Msat, t time.Time) string {
+	value, ok := fiatValue(priceProvider, amount, t)
+	if !ok {
+		return ""
+	}
+	p := message.NewPrinter(language.English)
+	currency := strings.ToUpper(priceProvider.Currency())
+	if symbol, exists := currencySymbols[currency]; exists {
+		if value < 0 {
+			return p.Sprintf("-%s%.2f", symbol, -value)
+		}
+		return p.Sprintf("%s%.2f", symbol, value)
+	}
+	return p.Sprintf("%.2f %s", value, currency)
+}
+
+// withFiat appends the current fiat value of sats to formatted when the user
+// chose to see fiat amounts.
+func (ui *UI) withFiat(formatted string, sats int64) string {
+	if !getSettings(ui).ShowFiat {
+		return formatted
+	}
+	fiat := formatFiatAmount(Sats(sats), time.Now())
+	if fiat == "" {
+		return formatted
+	}
+	return formatted + " [grey](" + fiat + ")"
+}
+
+// ToggleFiat shows or hides fiat amounts and redraws the current page.
+func (ui *UI) ToggleFiat() {
+	if priceProvider == nil {
+		ui.log.Warn("No price source, start cluster with --fiat-price, --prices or --price-url\n")
+		return
+	}
+	settings := getSettings(ui)
+	if !settings.ShowFiat {
+		if _, err := priceProvider.Price(time.Now()); err != nil {
+			ui.log.Warn("Can't get the " + priceProvider.Currency() + " price: " + err.Error() + "\n")
+			return
+		}
+	}
+	settings.ShowFiat = !settings.ShowFiat
+	saveSettings(ui)
+	if name, _ := ui.pages.GetFrontPage(); name != "" {
+		ui.ReloadPage(name)
+	}
+	ui.FocusMenu()
+}
+
+var errNoPriceSource = errors.New("no price source")
+
+// newPriceProvider picks the price source from the command line options, the
+// HTTP one first.
+func newPriceProvider(currency string, staticPrice float64, pricesPath, priceURL, pricePath string) (PriceProvider, error) {
+	switch {
+	case priceURL != "":
+		provider := NewHTTPPriceProvider(priceURL, pricePath, currency)
+		provider.Start()
+		return provider, nil
+	case pricesPath != "":
+		return LoadPriceHistory(pricesPath, currency)
+	case staticPrice > 0:
+		return NewStaticPriceProvider(currency, staticPrice), nil
+	}
+	return nil, errNoPriceSource
+}
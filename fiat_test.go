@@ -0,0 +1,100 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// waitForPrice polls p until its first fetch is done.
+func waitForPrice(t *testing.T, p *HTTPPriceProvider) (float64, error) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		price, err := p.Price(time.Now())
+		if err != errPriceNotFetched {
+			return price, err
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("the price was never fetched")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestHTTPPriceProvider(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"bitcoin": {"usd": 61234.5}}`))
+	}))
+	defer server.Close()
+
+	p := NewHTTPPriceProvider(server.URL, "bitcoin.usd", "USD")
+	// Price doesn't wait for the server
+	if _, err := p.Price(time.Now()); err != errPriceNotFetched {
+		t.Fatalf("Price before the first fetch returned %v, expected errPriceNotFetched", err)
+	}
+	close(release)
+
+	price, err := waitForPrice(t, p)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if price != 61234.5 {
+		t.Errorf("price %v, expected 61234.5", price)
+	}
+	// no history, any time gets the current price
+	if price, _ := p.Price(time.Date(2015, 1, 1, 0, 0, 0, 0, time.UTC)); price != 61234.5 {
+		t.Errorf("price in 2015 %v, expected the current 61234.5", price)
+	}
+}
+
+func TestHTTPPriceProviderErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		body    string
+		path    string
+		wantErr string
+	}{
+		{"server error", http.StatusInternalServerError, "", "price", "500"},
+		{"no price", http.StatusOK, `{"rate": 1}`, "price", `no price at "price"`},
+		{"zero price", http.StatusOK, `{"price": 0}`, "price", `no price at "price"`},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(test.status)
+				w.Write([]byte(test.body))
+			}))
+			defer server.Close()
+
+			p := NewHTTPPriceProvider(server.URL, test.path, "USD")
+			_, err := waitForPrice(t, p)
+			if err == nil || !strings.Contains(err.Error(), test.wantErr) {
+				t.Errorf("error %v, expected one containing %q", err, test.wantErr)
+			}
+		})
+	}
+}
+
+func TestHistoricalPriceProvider(t *testing.T) {
+	defer func(provider PriceProvider) { priceProvider = provider }(priceProvider)
+
+	priceProvider = NewHTTPPriceProvider("http://127.0.0.1:0", "price", "USD")
+	if historicalPriceProvider() != nil {
+		t.Error("the current price was used for past entries")
+	}
+	priceProvider = NewStaticPriceProvider("USD", 60000)
+	if historicalPriceProvider() != nil {
+		t.Error("the fixed price was used for past entries")
+	}
+	priceProvider = &PriceHistory{currency: "USD"}
+	if historicalPriceProvider() != priceProvider {
+		t.Error("the price history wasn't used for past entries")
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// number of source nodes sampled when approximating betweenness centrality
+const centralitySamples = 100
+
+// number of best connected candidates for which reachability gain is computed
+const reachabilityCandidates = 50
+
+type GraphNode struct {
+	node      Node
+	channels  int64
+	capacity  int64
+	medianFee int64
+	// median fee rate other nodes charge to reach this node
+	medianInboundFee int64
+	centrality       float64
+	distance         int
+	reachGain        int64
+	peer             bool
+}
+
+type Graph struct {
+	localID   string
+	nodes     map[string]*GraphNode
+	neighbors map[string][]string
+}
+
+var graphCache *Graph
+var lastGraphLookup time.Time
+// graphLock guards the cache, graphs aren't changed once built
+var graphLock sync.Mutex
+
+func median(values []int64) int64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sorted := append([]int64(nil), values...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	return sorted[len(sorted)/2]
+}
+
+// getGraph combines listnodes and listchannels into the network graph as seen
+// from our node.
+func getGraph(ui *UI) (*Graph, error) {
+
+	// cache
+	graphLock.Lock()
+	if graphCache != nil && lastGraphLookup.After(time.Now().Add(-cacheFor)) {
+		defer graphLock.Unlock()
+		return graphCache, nil
+	}
+	graphLock.Unlock()
+
+	info, err := getInfo(ui)
+	if err != nil {
+		return nil, err
+	}
+	nodes, err := listNodes(ui)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := listChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	// the fees of every channel, for the fee history
+	getFeeHistory(ui).RecordGossip(ui, channels)
+
+	g := &Graph{
+		localID:   info.Get("id").String(),
+		nodes:     make(map[string]*GraphNode),
+		neighbors: make(map[string][]string),
+	}
+	for _, node := range nodes {
+		g.nodes[node.id] = &GraphNode{node: node, distance: -1}
+	}
+
+	fees := make(map[string][]int64)
+	inboundFees := make(map[string][]int64)
+	linked := make(map[string]bool)
+	for _, channel := range channels.Get("channels").Array() {
+		source := channel.Get("source").String()
+		destination := channel.Get("destination").String()
+		for _, id := range []string{source, destination} {
+			if _, exists := g.nodes[id]; !exists {
+				g.nodes[id] = &GraphNode{node: Node{id: id}, distance: -1}
+			}
+		}
+		gn := g.nodes[source]
+		gn.channels += 1
+		gn.capacity += satOrMsatField(channel, "amount_msat", "satoshis").Sat()
+		fees[source] = append(fees[source], channel.Get("fee_per_millionth").Int())
+		if source != g.localID {
+			inboundFees[destination] = append(inboundFees[destination], channel.Get("fee_per_millionth").Int())
+		}
+
+		// both directions of a channel are listed, link the nodes once
+		if !linked[source+destination] && !linked[destination+source] {
+			linked[source+destination] = true
+			g.neighbors[source] = append(g.neighbors[source], destination)
+			g.neighbors[destination] = append(g.neighbors[destination], source)
+		}
+	}
+	for id, gn := range g.nodes {
+		gn.medianFee = median(fees[id])
+		gn.medianInboundFee = median(inboundFees[id])
+	}
+	for _, id := range g.neighbors[g.localID] {
+		g.nodes[id].peer = true
+	}
+
+	local := g.distances(g.localID)
+	for id, d := range local {
+		g.nodes[id].distance = d
+	}
+	g.computeCentrality()
+	g.computeReachGain(local)
+
+	graphLock.Lock()
+	graphCache = g
+	lastGraphLookup = time.Now()
+	graphLock.Unlock()
+	return g, nil
+}
+
+// distances returns the number of hops from source to every reachable node.
+func (g *Graph) distances(source string) map[string]int {
+	dist := map[string]int{source: 0}
+	queue := []string{source}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		for _, w := range g.neighbors[v] {
+			if _, seen := dist[w]; !seen {
+				dist[w] = dist[v] + 1
+				queue = append(queue, w)
+			}
+		}
+	}
+	return dist
+}
+
+// computeCentrality approximates the betweenness centrality of every node
+// using Brandes' algorithm from a sample of source nodes.
+func (g *Graph) computeCentrality() {
+	ids := make([]string, 0, len(g.neighbors))
+	for id := range g.neighbors {
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		return
+	}
+	sort.Strings(ids)
+	// a fixed seed keeps the ranking stable between reloads
+	r := rand.New(rand.NewSource(1))
+	r.Shuffle(len(ids), func(i, j int) { ids[i], ids[j] = ids[j], ids[i] })
+	samples := ids
+	if len(samples) > centralitySamples {
+		samples = samples[:centralitySamples]
+	}
+
+	betweenness := make(map[string]float64)
+	for _, s := range samples {
+		var stack []string
+		predecessors := make(map[string][]string)
+		sigma := map[string]float64{s: 1}
+		dist := map[string]int{s: 0}
+		queue := []string{s}
+		for len(queue) > 0 {
+			v := queue[0]
+			queue = queue[1:]
+			stack = append(stack, v)
+			for _, w := range g.neighbors[v] {
+				if _, seen := dist[w]; !seen {
+					dist[w] = dist[v] + 1
+					queue = append(queue, w)
+				}
+				if dist[w] == dist[v]+1 {
+					sigma[w] += sigma[v]
+					predecessors[w] = append(predecessors[w], v)
+				}
+			}
+		}
+		delta := make(map[string]float64)
+		for i := len(stack) - 1; i >= 0; i-- {
+			w := stack[i]
+			for _, v := range predecessors[w] {
+				delta[v] += sigma[v] / sigma[w] * (1 + delta[w])
+			}
+			if w != s {
+				betweenness[w] += delta[w]
+			}
+		}
+	}
+
+	max := 0.0
+	for _, b := range betweenness {
+		if b > max {
+			max = b
+		}
+	}
+	if max == 0 {
+		return
+	}
+	for id, b := range betweenness {
+		g.nodes[id].centrality = b / max
+	}
+}
+
+// computeReachGain counts, for the best connected nodes we don't have a
+// channel with, how many nodes would get closer to us if we opened one.
+func (g *Graph) computeReachGain(local map[string]int) {
+	var candidates []*GraphNode
+	for id, gn := range g.nodes {
+		if id != g.localID && !gn.peer && gn.channels > 0 {
+			candidates = append(candidates, gn)
+		}
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].capacity > candidates[j].capacity
+	})
+	if len(candidates) > reachabilityCandidates {
+		candidates = candidates[:reachabilityCandidates]
+	}
+
+	for _, candidate := range candidates {
+		gain := int64(0)
+		for id, d := range g.distances(candidate.node.id) {
+			current, reachable := local[id]
+			if !reachable || d+1 < current {
+				gain += 1
+			}
+		}
+		candidate.reachGain = gain
+	}
+}
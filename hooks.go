@@ -0,0 +1,140 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// how long hooks may run when they don't set a timeout
+const defaultHookTimeout = 30 * time.Second
+
+// at most this much of the output of hooks is kept in the result log
+const maxHookOutput = 4096
+
+// Hook is a command run on events of a type, with the event as JSON on
+// stdin.
+type Hook struct {
+	Event   string `json:"event"`
+	Command string `json:"command"`
+	Timeout string `json:"timeout,omitempty"`
+
+	timeout time.Duration
+}
+
+// HookResult is how a hook run went, as kept in hooks.log.
+type HookResult struct {
+	Time     int64  `json:"time"`
+	Event    string `json:"event"`
+	Command  string `json:"command"`
+	ExitCode int    `json:"exit_code"`
+	Duration int64  `json:"duration_ms"`
+	Output   string `json:"output,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+var hooks []Hook
+
+// loadHooks reads the hooks from a JSON file such as
+//
+//	[{"event": "channel_opened", "command": "./post-to-chat.sh", "timeout": "10s"}]
+//
+// A missing file means there are no hooks.
+func loadHooks(path string) error {
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var loaded []Hook
+	if err := json.Unmarshal(data, &loaded); err != nil {
+		return err
+	}
+	for i := range loaded {
+		hook := &loaded[i]
+		if !validEventType(hook.Event) {
+			return fmt.Errorf("unknown event %q, expected one of %s", hook.Event, strings.Join(eventTypes, ", "))
+		}
+		if hook.Command == "" {
+			return fmt.Errorf("hook on %s has no command", hook.Event)
+		}
+		hook.timeout = defaultHookTimeout
+		if hook.Timeout != "" {
+			if hook.timeout, err = time.ParseDuration(hook.Timeout); err != nil {
+				return fmt.Errorf("hook on %s: %s", hook.Event, err)
+			}
+		}
+	}
+	hooks = loaded
+	return nil
+}
+
+func validEventType(eventType string) bool {
+	for _, t := range eventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// StartHooks runs the hooks of the events published, one at a time in the
+// order of the file, and logs how they went to hooks.log.
+func (ui *UI) StartHooks() {
+	if len(hooks) == 0 {
+		return
+	}
+	path := ui.dataPath("hooks.log")
+	events.Subscribe(func(e Event) {
+		for _, hook := range hooks {
+			if hook.Event != e.Type {
+				continue
+			}
+			result := runHook(hook, e)
+			if result.Error != "" {
+				ui.log.Warn(fmt.Sprintf("Hook on %s failed: %s\n", e.Type, result.Error))
+			} else {
+				ui.log.Ok(fmt.Sprintf("Hook on %s done [%dms]\n", e.Type, result.Duration))
+			}
+			if err := appendJSONLines(path, result); err != nil {
+				ui.log.Warn("Can't write hook log " + path + ": " + err.Error() + "\n")
+			}
+		}
+	})
+}
+
+func runHook(hook Hook, e Event) HookResult {
+	ctx, cancel := context.WithTimeout(context.Background(), hook.timeout)
+	defer cancel()
+
+	start := time.Now()
+	output, err := runEventCommand(ctx, hook.Command, e)
+	result := HookResult{
+		Time:     start.Unix(),
+		Event:    e.Type,
+		Command:  hook.Command,
+		Duration: time.Since(start).Milliseconds(),
+	}
+	if len(output) > maxHookOutput {
+		output = output[:maxHookOutput]
+	}
+	result.Output = string(output)
+	if err == context.DeadlineExceeded {
+		result.ExitCode = -1
+		result.Error = "timed out after " + hook.timeout.String()
+	} else if exitError, ok := err.(*exec.ExitError); ok {
+		result.ExitCode = exitError.ExitCode()
+		result.Error = exitError.Error()
+	} else if err != nil {
+		result.ExitCode = -1
+		result.Error = err.Error()
+	}
+	return result
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kinds of ledger entries
+const (
+	LedgerInvoiceReceived = "invoice_received"
+	LedgerPaymentSent     = "payment_sent"
+	LedgerRoutingFee      = "routing_fee"
+	LedgerRebalance       = "rebalance"
+	LedgerChannelOpenFee  = "channel_open_fee"
+	LedgerChannelCloseFee = "channel_close_fee"
+	LedgerOnchainFee      = "onchain_fee"
+	LedgerDeposit         = "deposit"
+	LedgerWithdrawal      = "withdrawal"
+)
+
+// LedgerEntry is a movement of our funds. amount is positive for what we
+// receive and negative for what we send, fee is what it cost us on top.
+type LedgerEntry struct {
+	time        time.Time
+	kind        string
+	amount      Msat
+	fee         Msat
+	reference   string
+	description string
+}
+
+// getLedger collects every invoice paid to us, payment, settled forward and,
+// when the bookkeeper plugin is running, the on-chain fees and wallet
+// deposits and withdrawals. Without it the input amounts and times of
+// on-chain transactions are unknown, so these are left out with a warning.
+// Wallet movements funding or closing our channels are transfers between our
+// own accounts and are left out, their fees are not. Amounts are in msat.
+func getLedger(ui *UI) ([]LedgerEntry, error) {
+	var ledger []LedgerEntry
+	info, err := getInfo(ui)
+	if err != nil {
+		return nil, err
+	}
+	localID := info.Get("id").String()
+
+	invoices, err := getInvoices(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, invoice := range invoices.Get("invoices").Array() {
+		if invoice.Get("status").String() != "paid" {
+			continue
+		}
+		amount := msatValue(invoice, "amount_received")
+		if amount == 0 {
+			amount = msatField(invoice, "msatoshi_received")
+		}
+		description := invoice.Get("description").String()
+		if description == "" {
+			description = invoice.Get("label").String()
+		}
+		ledger = append(ledger, LedgerEntry{
+			time:        time.Unix(invoice.Get("paid_at").Int(), 0),
+			kind:        LedgerInvoiceReceived,
+			amount:      amount,
+			reference:   invoice.Get("payment_hash").String(),
+			description: description,
+		})
+	}
+
+	pays, err := getPays(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, pay := range pays.Get("pays").Array() {
+		if pay.Get("status").String() != "complete" {
+			continue
+		}
+		ts := pay.Get("completed_at").Int()
+		if ts == 0 {
+			ts = pay.Get("created_at").Int()
+		}
+		amount := msatValue(pay, "amount")
+		entry := LedgerEntry{
+			time:        time.Unix(ts, 0),
+			kind:        LedgerPaymentSent,
+			amount:      -amount,
+			fee:         msatValue(pay, "amount_sent") - amount,
+			reference:   pay.Get("payment_hash").String(),
+			description: pay.Get("label").String(),
+		}
+		if pay.Get("destination").String() == localID {
+			// the amount comes back to us, only the fee is spent
+			entry.kind = LedgerRebalance
+			entry.amount = 0
+		}
+		ledger = append(ledger, entry)
+	}
+
+	forwards, err := getForwards(ui, map[string]interface{}{"status": "settled"})
+	if err != nil {
+		return nil, err
+	}
+	for _, forward := range forwards.Get("forwards").Array() {
+		ts := forward.Get("resolved_time").Float()
+		if ts == 0 {
+			ts = forward.Get("received_time").Float()
+		}
+		ledger = append(ledger, LedgerEntry{
+			time:        time.Unix(int64(ts), 0),
+			kind:        LedgerRoutingFee,
+			amount:      msatValue(forward, "fee"),
+			reference:   forward.Get("in_channel").String() + " -> " + forward.Get("out_channel").String(),
+			description: "routing fee",
+		})
+	}
+
+	// without the bookkeeper plugin there are no on-chain events
+	accountEvents, err := listAccountEvents(ui)
+	if isUnknownCommand(err) {
+		ui.log.Warn("The bookkeeper plugin isn't running, the ledger lacks the on-chain fees, deposits and withdrawals\n")
+	} else if err != nil {
+		return nil, err
+	}
+	events := accountEvents.Get("events").Array()
+	// transactions opening and closing our channels
+	channelTxs := make(map[string]string)
+	for _, event := range events {
+		switch event.Get("tag").String() {
+		case "channel_open":
+			channelTxs[strings.Split(event.Get("outpoint").String(), ":")[0]] = LedgerChannelOpenFee
+		case "channel_close":
+			channelTxs[event.Get("txid").String()] = LedgerChannelCloseFee
+		}
+	}
+	for _, event := range events {
+		txid := event.Get("txid").String()
+		if txid == "" {
+			txid = strings.Split(event.Get("outpoint").String(), ":")[0]
+		}
+		credit := msatValue(event, "credit")
+		debit := msatValue(event, "debit")
+		entry := LedgerEntry{
+			time:      time.Unix(event.Get("timestamp").Int(), 0),
+			reference: txid,
+		}
+		switch event.Get("tag").String() {
+		case "onchain_fee":
+			entry.fee = credit - debit
+			if entry.fee == 0 {
+				continue
+			}
+			entry.kind = LedgerOnchainFee
+			if kind, exists := channelTxs[txid]; exists {
+				entry.kind = kind
+			}
+			entry.description = "on-chain fee"
+		case "deposit":
+			if event.Get("account").String() != "wallet" {
+				continue
+			}
+			if _, exists := channelTxs[txid]; exists {
+				continue
+			}
+			entry.kind = LedgerDeposit
+			entry.amount = credit
+			entry.description = "wallet deposit"
+		case "withdrawal":
+			if event.Get("account").String() != "wallet" {
+				continue
+			}
+			if _, exists := channelTxs[txid]; exists {
+				continue
+			}
+			entry.kind = LedgerWithdrawal
+			entry.amount = -debit
+			entry.description = "wallet withdrawal"
+		default:
+			continue
+		}
+		ledger = append(ledger, entry)
+	}
+
+	sort.SliceStable(ledger, func(i, j int) bool { return ledger[i].time.Before(ledger[j].time) })
+	return ledger, nil
+}
+
+// formatFiat keeps the fractions of a cent, fees are often worth less.
+func formatFiat(value float64) string {
+	s := strings.TrimRight(fmt.Sprintf("%.8f", value), "0")
+	if strings.HasSuffix(s, ".") {
+		s += "00"
+	}
+	return s
+}
+
+func ledgerExport(ledger []LedgerEntry) Export {
+	e := Export{Columns: []string{"time", "kind", "amount_msat", "fee_msat", "reference", "description"}}
+	provider := historicalPriceProvider()
+	if provider != nil {
+		e.Columns = append(e.Columns, "fiat_amount", "fiat_fee", "fiat_currency")
+	}
+	var amount, fee Msat
+	for _, entry := range ledger {
+		row := []interface{}{entry.time, entry.kind, entry.amount, entry.fee, entry.reference, entry.description}
+		if provider != nil {
+			fiatAmount, ok := fiatValue(provider, entry.amount, entry.time)
+			fiatFee, _ := fiatValue(provider, entry.fee, entry.time)
+			if ok {
+				row = append(row, formatFiat(fiatAmount), formatFiat(fiatFee), provider.Currency())
+			} else {
+				row = append(row, nil, nil, nil)
+			}
+		}
+		e.Rows = append(e.Rows, row)
+		amount += entry.amount
+		fee += entry.fee
+	}
+	e.Totals = make([]interface{}, len(e.Columns))
+	e.Totals[2] = amount
+	e.Totals[3] = fee
+	e.writers = map[string]func(w io.Writer) error{
+		"koinly": func(w io.Writer) error { return writeKoinlyCSV(w, ledger) },
+	}
+	return e
+}
+
+// koinlyLabel tags the entries Koinly doesn't treat as plain transfers.
+func koinlyLabel(kind string) string {
+	switch kind {
+	case LedgerRoutingFee:
+		return "income"
+	case LedgerRebalance, LedgerChannelOpenFee, LedgerChannelCloseFee, LedgerOnchainFee:
+		return "cost"
+	}
+	return ""
+}
+
+// writeKoinlyCSV writes ledger in the Koinly universal format. Entries with a
+// fee only are written as a cost.
+func writeKoinlyCSV(w io.Writer, ledger []LedgerEntry) error {
+	provider := historicalPriceProvider()
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{
+		"Date", "Sent Amount", "Sent Currency", "Received Amount", "Received Currency",
+		"Fee Amount", "Fee Currency", "Net Worth Amount", "Net Worth Currency",
+		"Label", "Description", "TxHash",
+	})
+	if err != nil {
+		return err
+	}
+	for _, entry := range ledger {
+		record := make([]string, 12)
+		record[0] = entry.time.UTC().Format("2006-01-02 15:04:05") + " UTC"
+		value := entry.amount
+		switch {
+		case entry.amount > 0:
+			record[3] = entry.amount.BTC()
+			record[4] = "BTC"
+		case entry.amount < 0:
+			record[1] = (-entry.amount).BTC()
+			record[2] = "BTC"
+			value = -entry.amount
+		default:
+			record[1] = entry.fee.BTC()
+			record[2] = "BTC"
+			value = entry.fee
+		}
+		if entry.amount != 0 && entry.fee > 0 {
+			record[5] = entry.fee.BTC()
+			record[6] = "BTC"
+		}
+		if fiat, ok := fiatValue(provider, value, entry.time); ok {
+			record[7] = formatFiat(fiat)
+			record[8] = provider.Currency()
+		}
+		record[9] = koinlyLabel(entry.kind)
+		record[10] = entry.description
+		record[11] = entry.reference
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
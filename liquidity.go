@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// how long liquidity learnt from probes is trusted when choosing routes,
+// payments and rebalancing move it around
+const liquidityFreshness = time.Hour
+
+// LiquidityEstimate bounds the funds one side of a remote channel can
+// forward, as learnt from probes at time (unix seconds). Min is an amount
+// which went through, Max one which failed, so the liquidity is at least Min
+// and below Max. A zero Max means no upper bound is known.
+type LiquidityEstimate struct {
+	Time           int64  `json:"time"`
+	ShortChannelID string `json:"scid"`
+	Direction      int    `json:"direction"`
+	Min            Msat   `json:"min_msat"`
+	Max            Msat   `json:"max_msat"`
+}
+
+type LiquidityEstimates struct {
+	path      string
+	estimates map[string]LiquidityEstimate
+}
+
+var liquidityEstimates *LiquidityEstimates
+
+func liquidityKey(shortChannelID string, direction int) string {
+	return fmt.Sprintf("%s/%d", shortChannelID, direction)
+}
+
+// getLiquidityEstimates loads the estimates recorded so far, the last one of
+// each channel side being the current one.
+func getLiquidityEstimates(ui *UI) *LiquidityEstimates {
+	if liquidityEstimates != nil {
+		return liquidityEstimates
+	}
+	l := &LiquidityEstimates{
+		path:      ui.dataPath("liquidity.jsonl"),
+		estimates: make(map[string]LiquidityEstimate),
+	}
+	err := readJSONLines(l.path, func(line []byte) error {
+		var estimate LiquidityEstimate
+		if err := json.Unmarshal(line, &estimate); err != nil {
+			return err
+		}
+		l.estimates[liquidityKey(estimate.ShortChannelID, estimate.Direction)] = estimate
+		return nil
+	})
+	if err != nil {
+		ui.log.Warn("Can't read liquidity estimates " + l.path + ": " + err.Error() + "\n")
+	}
+	liquidityEstimates = l
+	return liquidityEstimates
+}
+
+// Get returns the estimate of a channel side if it is fresh enough to go by.
+func (l *LiquidityEstimates) Get(shortChannelID string, direction int) (LiquidityEstimate, bool) {
+	estimate, exists := l.estimates[liquidityKey(shortChannelID, direction)]
+	if !exists || time.Since(time.Unix(estimate.Time, 0)) > liquidityFreshness {
+		return LiquidityEstimate{}, false
+	}
+	return estimate, true
+}
+
+// Forwarded records that amount went through a channel side.
+func (l *LiquidityEstimates) Forwarded(ui *UI, shortChannelID string, direction int, amount Msat) {
+	estimate, _ := l.Get(shortChannelID, direction)
+	if amount > estimate.Min {
+		estimate.Min = amount
+	}
+	if estimate.Max != 0 && estimate.Max <= amount {
+		// the liquidity moved since
+		estimate.Max = 0
+	}
+	l.record(ui, shortChannelID, direction, estimate)
+}
+
+// Failed records that amount couldn't go through a channel side.
+func (l *LiquidityEstimates) Failed(ui *UI, shortChannelID string, direction int, amount Msat) {
+	estimate, _ := l.Get(shortChannelID, direction)
+	if estimate.Max == 0 || amount < estimate.Max {
+		estimate.Max = amount
+	}
+	if estimate.Min >= amount {
+		estimate.Min = 0
+	}
+	l.record(ui, shortChannelID, direction, estimate)
+}
+
+func (l *LiquidityEstimates) record(ui *UI, shortChannelID string, direction int, estimate LiquidityEstimate) {
+	estimate.Time = time.Now().Unix()
+	estimate.ShortChannelID = shortChannelID
+	estimate.Direction = direction
+	l.estimates[liquidityKey(shortChannelID, direction)] = estimate
+	if err := appendJSONLines(l.path, estimate); err != nil {
+		ui.log.Warn("Can't write liquidity estimates " + l.path + ": " + err.Error() + "\n")
+	}
+}
+
+// Exclusions lists the channel sides known to be short of amount, in the
+// form getroute and pay take in exclude.
+func (l *LiquidityEstimates) Exclusions(amount Msat) []string {
+	var excluded []string
+	for key, estimate := range l.estimates {
+		if fresh, _ := l.Get(estimate.ShortChannelID, estimate.Direction); fresh.Max != 0 && fresh.Max <= amount {
+			excluded = append(excluded, key)
+		}
+	}
+	sort.Strings(excluded)
+	return excluded
+}
@@ -3,15 +3,32 @@ package main
 import (
 	"fmt"
 	"github.com/rivo/tview"
+	"io"
+	"sync"
 )
 
 type Log struct {
 	view *tview.TextView
 	buf  []string
 	c    chan string
+	// console is set when logging outside of the UI
+	console io.Writer
+	// closed once every message is written, see Close
+	done   chan struct{}
+	closed bool
+	lock   sync.Mutex
 }
 
 func NewLog() *Log {
+	return newLog(nil)
+}
+
+// NewConsoleLog writes the messages to w without their colors.
+func NewConsoleLog(w io.Writer) *Log {
+	return newLog(w)
+}
+
+func newLog(console io.Writer) *Log {
 
 	v := tview.NewTextView()
 	v.SetTitle("                                                               Activity ")
@@ -21,28 +38,57 @@ func NewLog() *Log {
 	v.SetTextColor(TextColor)
 	v.SetScrollable(false)
 	log := &Log{
-		view: v,
-		buf:  []string{"", "", "", "", ""},
-		c:    make(chan string),
+		view:    v,
+		buf:     []string{"", "", "", "", ""},
+		c:       make(chan string),
+		console: console,
+		done:    make(chan struct{}),
 	}
 	go log.Start()
 	return log
 }
 
 func (l *Log) Info(message string) {
-	l.c <- "[deepskyblue]" + message
+	l.send("[deepskyblue]" + message)
 }
 
 func (l *Log) Warn(message string) {
-	l.c <- "[red]" + message
+	l.send("[red]" + message)
 }
 
 func (l *Log) Ok(message string) {
-	l.c <- "[green]" + message
+	l.send("[green]" + message)
+}
+
+// send hands m to Start, messages after Close are dropped.
+func (l *Log) send(m string) {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+	if l.closed {
+		return
+	}
+	l.c <- m
+}
+
+// Close waits for the messages logged so far to be written, so none are
+// lost when cluster exits.
+func (l *Log) Close() {
+	l.lock.Lock()
+	if !l.closed {
+		l.closed = true
+		close(l.c)
+	}
+	l.lock.Unlock()
+	<-l.done
 }
 
 func (l *Log) Start() {
+	defer close(l.done)
 	for m := range l.c {
+		if l.console != nil {
+			fmt.Fprintf(l.console, "%s", stripColors(m))
+			continue
+		}
 		fmt.Fprintf(l.view,  "%s", m)
 	}
 }
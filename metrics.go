@@ -0,0 +1,288 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// points of a series closer than this are not recorded
+const minSampleInterval = time.Minute
+
+const sparklineTicks = "▁▂▃▄▅▆▇█"
+
+type MetricPoint struct {
+	Time   int64   `json:"t"`
+	Series string  `json:"s"`
+	Value  float64 `json:"v"`
+}
+
+// MetricStore is a time-series store keeping one file of points per day in
+// its directory. Days older than retention are deleted and days older than
+// downsampleAfter are averaged into buckets of resolution.
+type MetricStore struct {
+	dir             string
+	retention       time.Duration
+	downsampleAfter time.Duration
+	resolution      time.Duration
+	series          map[string][]MetricPoint
+	mu              sync.Mutex
+
+	// the file of the day points are appended to, kept open between points
+	day     string
+	file    *os.File
+	encoder *json.Encoder
+}
+
+func dayFile(t time.Time) string {
+	return t.UTC().Format("2006-01-02") + ".jsonl"
+}
+
+func NewMetricStore(dir string, retention, downsampleAfter, resolution time.Duration) *MetricStore {
+	return &MetricStore{
+		dir:             dir,
+		retention:       retention,
+		downsampleAfter: downsampleAfter,
+		resolution:      resolution,
+	}
+}
+
+// Open applies the retention and downsampling policy to the stored days and
+// loads them in memory. Corrupt lines, such as one cut short by a crash, are
+// skipped and dropped from their day file, Open returns how many.
+func (m *MetricStore) Open() (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closeDay()
+	m.series = make(map[string][]MetricPoint)
+	if err := os.MkdirAll(m.dir, 0700); err != nil {
+		return 0, err
+	}
+	files, err := ioutil.ReadDir(m.dir)
+	if err != nil {
+		return 0, err
+	}
+	skipped := 0
+	now := time.Now().UTC()
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".jsonl") {
+			continue
+		}
+		path := filepath.Join(m.dir, file.Name())
+		day, err := time.Parse("2006-01-02", strings.TrimSuffix(file.Name(), ".jsonl"))
+		if err != nil {
+			continue
+		}
+		if m.retention > 0 && day.Before(now.Add(-m.retention)) {
+			if err := os.Remove(path); err != nil {
+				return skipped, err
+			}
+			continue
+		}
+
+		var points []MetricPoint
+		corrupt := 0
+		err = readJSONLines(path, func(line []byte) error {
+			var point MetricPoint
+			if err := json.Unmarshal(line, &point); err != nil {
+				corrupt += 1
+				return nil
+			}
+			points = append(points, point)
+			return nil
+		})
+		if err != nil {
+			return skipped, err
+		}
+		skipped += corrupt
+
+		rewrite := corrupt > 0
+		if m.downsampleAfter > 0 && day.Add(24*time.Hour).Before(now.Add(-m.downsampleAfter)) {
+			downsampled := downsample(points, m.resolution)
+			if len(downsampled) < len(points) {
+				rewrite = true
+				points = downsampled
+			}
+		}
+		if rewrite {
+			if err := rewriteDay(path, points); err != nil {
+				return skipped, err
+			}
+		}
+		for _, point := range points {
+			m.series[point.Series] = append(m.series[point.Series], point)
+		}
+	}
+	for name := range m.series {
+		points := m.series[name]
+		sort.SliceStable(points, func(i, j int) bool { return points[i].Time < points[j].Time })
+	}
+	return skipped, nil
+}
+
+// downsample averages the points of every series into buckets of resolution.
+func downsample(points []MetricPoint, resolution time.Duration) []MetricPoint {
+	bucketSize := int64(resolution.Seconds())
+	if bucketSize <= 0 {
+		return points
+	}
+	type bucket struct {
+		series string
+		time   int64
+	}
+	sums := make(map[bucket]float64)
+	counts := make(map[bucket]int)
+	var order []bucket
+	for _, point := range points {
+		b := bucket{point.Series, point.Time - point.Time%bucketSize}
+		if counts[b] == 0 {
+			order = append(order, b)
+		}
+		sums[b] += point.Value
+		counts[b] += 1
+	}
+	var results []MetricPoint
+	for _, b := range order {
+		results = append(results, MetricPoint{b.time, b.series, sums[b] / float64(counts[b])})
+	}
+	return results
+}
+
+func rewriteDay(path string, points []MetricPoint) error {
+	tmp := path + ".tmp"
+	os.Remove(tmp)
+	records := make([]interface{}, len(points))
+	for i, point := range points {
+		records[i] = point
+	}
+	if err := appendJSONLines(tmp, records...); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// Record adds a point with the current time to series.
+func (m *MetricStore) Record(series string, value float64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.series == nil {
+		return nil
+	}
+	now := time.Now()
+	points := m.series[series]
+	if len(points) > 0 && now.Unix()-points[len(points)-1].Time < int64(minSampleInterval.Seconds()) {
+		return nil
+	}
+	point := MetricPoint{now.Unix(), series, value}
+	m.series[series] = append(points, point)
+
+	if day := dayFile(now); day != m.day {
+		m.closeDay()
+		f, err := os.OpenFile(filepath.Join(m.dir, day), os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		m.day, m.file, m.encoder = day, f, json.NewEncoder(f)
+	}
+	return m.encoder.Encode(point)
+}
+
+func (m *MetricStore) closeDay() {
+	if m.file != nil {
+		m.file.Close()
+	}
+	m.day, m.file, m.encoder = "", nil, nil
+}
+
+// Close closes the file points are appended to.
+func (m *MetricStore) Close() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.closeDay()
+}
+
+// Points returns the points of series recorded since.
+func (m *MetricStore) Points(series string, since time.Time) []MetricPoint {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var results []MetricPoint
+	for _, point := range m.series[series] {
+		if point.Time >= since.Unix() {
+			results = append(results, point)
+		}
+	}
+	return results
+}
+
+// Sparkline draws series over the last period as width characters, each
+// showing the last value recorded in its slot.
+func (m *MetricStore) Sparkline(series string, period time.Duration, width int) string {
+	from := time.Now().Add(-period)
+	points := m.Points(series, from)
+	if len(points) == 0 {
+		return ""
+	}
+
+	slots := make([]float64, width)
+	filled := make([]bool, width)
+	slotSize := period.Seconds() / float64(width)
+	for _, point := range points {
+		slot := int(float64(point.Time-from.Unix()) / slotSize)
+		if slot >= width {
+			slot = width - 1
+		}
+		slots[slot] = point.Value
+		filled[slot] = true
+	}
+
+	min := math.Inf(1)
+	max := math.Inf(-1)
+	for i := range slots {
+		if filled[i] {
+			min = math.Min(min, slots[i])
+			max = math.Max(max, slots[i])
+		}
+	}
+
+	ticks := []rune(sparklineTicks)
+	var sb strings.Builder
+	for i := range slots {
+		if !filled[i] {
+			sb.WriteRune(' ')
+			continue
+		}
+		tick := 0
+		if max > min {
+			tick = int((slots[i] - min) / (max - min) * float64(len(ticks)-1))
+		}
+		sb.WriteRune(ticks[tick])
+	}
+	return sb.String()
+}
+
+// recordMetric stores a point, reporting failures in the log.
+func (ui *UI) recordMetric(series string, value float64) {
+	if ui.metrics == nil {
+		return
+	}
+	if err := ui.metrics.Record(series, value); err != nil {
+		ui.log.Warn("Can't record " + series + ": " + err.Error() + "\n")
+	}
+}
+
+func boolMetric(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
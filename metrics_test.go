@@ -0,0 +1,52 @@
+package main
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMetricStoreCorruptLines(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now().UTC()
+	yesterday := now.Add(-24 * time.Hour)
+	days := map[string]string{
+		// cut short by a crash, then appended to
+		dayFile(yesterday): `{"t":1,"s":"balance","v":1}` + "\n" + `{"t":2,"s":"bal` + `{"t":3,"s":"balance","v":3}` + "\n",
+		dayFile(now):       `{"t":4,"s":"balance","v":4}` + "\n",
+	}
+	for name, content := range days {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	m := NewMetricStore(dir, 0, 0, time.Hour)
+	skipped, err := m.Open()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer m.Close()
+	if skipped != 1 {
+		t.Errorf("skipped %d lines, expected 1", skipped)
+	}
+	// the days after the corrupt line are loaded too
+	if points := m.series["balance"]; len(points) != 2 || points[0].Time != 1 || points[1].Time != 4 {
+		t.Errorf("points %+v, expected those at 1 and 4", points)
+	}
+
+	// the corrupt line is dropped from the file
+	repaired, err := ioutil.ReadFile(filepath.Join(dir, dayFile(yesterday)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(string(repaired)) != `{"t":1,"s":"balance","v":1}` {
+		t.Errorf("day file %q, expected only the valid point", repaired)
+	}
+	m.Close()
+	if skipped, err := m.Open(); err != nil || skipped != 0 {
+		t.Errorf("reopening skipped %d lines, %v, expected none", skipped, err)
+	}
+}
@@ -0,0 +1,237 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// Monitor polls the node in the background and publishes what changed
+// since the previous poll as events. The first poll only takes stock, so
+// starting cluster doesn't replay the node's history.
+type Monitor struct {
+	ui              *UI
+	disconnectAfter time.Duration
+
+	polled       bool
+	channels     map[string]string
+	active       map[string]bool
+	disconnected map[string]time.Time
+	reported     map[string]bool
+	lastForward  float64
+	lastPayIndex int64
+	outputs      map[string]string
+	failedPays   map[string]bool
+}
+
+func NewMonitor(ui *UI, disconnectAfter time.Duration) *Monitor {
+	return &Monitor{
+		ui:              ui,
+		disconnectAfter: disconnectAfter,
+		channels:        make(map[string]string),
+		active:          make(map[string]bool),
+		disconnected:    make(map[string]time.Time),
+		reported:        make(map[string]bool),
+		outputs:         make(map[string]string),
+		failedPays:      make(map[string]bool),
+	}
+}
+
+// Start polls every interval in the background.
+func (m *Monitor) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for {
+			m.Poll()
+			time.Sleep(interval)
+		}
+	}()
+}
+
+// Poll looks for changes and publishes them. Like the sampler it calls
+// lightningd silently, and skips what it can't read until the next poll.
+func (m *Monitor) Poll() {
+	info, err := callSilently(m.ui, "getinfo")
+	if err != nil {
+		return
+	}
+	localID := info.Get("id").String()
+
+	if results, err := callSilently(m.ui, peerChannelsMethod(getNodeVersion(m.ui))); err == nil {
+		m.checkChannels(peerChannels(results))
+	}
+	if results, err := callSilently(m.ui, "listchannels", map[string]interface{}{"source": localID}); err == nil {
+		active := make(map[string]bool)
+		for _, channel := range results.Get("channels").Array() {
+			active[channel.Get("short_channel_id").String()] = channel.Get("active").Bool()
+		}
+		m.checkActive(active)
+	}
+	if results, err := callSilently(m.ui, "listforwards", map[string]interface{}{"status": "settled"}); err == nil {
+		lastForward := m.lastForward
+		for _, forward := range results.Get("forwards").Array() {
+			resolved := forward.Get("resolved_time").Float()
+			if resolved <= m.lastForward {
+				continue
+			}
+			if resolved > lastForward {
+				lastForward = resolved
+			}
+			if m.polled {
+				m.publish(EventForwardSettled, "Forward settled",
+					fmt.Sprintf("%s from %s to %s, earning %s", msatValue(forward, "out").Format(),
+						forward.Get("in_channel"), forward.Get("out_channel"), msatValue(forward, "fee").Format()),
+					map[string]interface{}{
+						"in_channel":  forward.Get("in_channel").String(),
+						"out_channel": forward.Get("out_channel").String(),
+						"out_msat":    int64(msatValue(forward, "out")),
+						"fee_msat":    int64(msatValue(forward, "fee")),
+					})
+			}
+		}
+		m.lastForward = lastForward
+	}
+	if results, err := callSilently(m.ui, "listinvoices"); err == nil {
+		lastPayIndex := m.lastPayIndex
+		for _, invoice := range results.Get("invoices").Array() {
+			payIndex := invoice.Get("pay_index").Int()
+			if invoice.Get("status").String() != "paid" || payIndex <= m.lastPayIndex {
+				continue
+			}
+			if payIndex > lastPayIndex {
+				lastPayIndex = payIndex
+			}
+			if !m.polled {
+				continue
+			}
+			received := msatField(invoice, "amount_received_msat", "msatoshi_received")
+			m.publish(EventInvoicePaid, "Invoice paid",
+				fmt.Sprintf("%s received for %s", received.Format(), formatDesc(invoice.Get("description").String())),
+				map[string]interface{}{
+					"label":        invoice.Get("label").String(),
+					"payment_hash": invoice.Get("payment_hash").String(),
+					"description":  invoice.Get("description").String(),
+					"amount_msat":  int64(received),
+				})
+		}
+		m.lastPayIndex = lastPayIndex
+	}
+	if results, err := callSilently(m.ui, "listfunds"); err == nil {
+		outputs := make(map[string]string)
+		for _, output := range results.Get("outputs").Array() {
+			outpoint := fmt.Sprintf("%s:%d", output.Get("txid").String(), output.Get("output").Int())
+			status := output.Get("status").String()
+			outputs[outpoint] = status
+			if !m.polled || status != "confirmed" || m.outputs[outpoint] == "confirmed" {
+				continue
+			}
+			amount := satOrMsatField(output, "amount_msat", "value")
+			m.publish(EventFundsConfirmed, "Funds confirmed",
+				fmt.Sprintf("%s confirmed on chain in block %d", amount.Format(), output.Get("blockheight").Int()),
+				map[string]interface{}{
+					"outpoint":    outpoint,
+					"address":     output.Get("address").String(),
+					"amount_msat": int64(amount),
+					"blockheight": output.Get("blockheight").Int(),
+				})
+		}
+		m.outputs = outputs
+	}
+	if results, err := callSilently(m.ui, "listpays"); err == nil {
+		for _, pay := range results.Get("pays").Array() {
+			paymentHash := pay.Get("payment_hash").String()
+			if pay.Get("status").String() != "failed" || m.failedPays[paymentHash] {
+				continue
+			}
+			m.failedPays[paymentHash] = true
+			if !m.polled {
+				continue
+			}
+			amount := msatField(pay, "amount_msat", "amount_sent_msat")
+			m.publish(EventPaymentFailed, "Payment failed",
+				fmt.Sprintf("Payment of %s to %s failed", amount.Format(), pay.Get("destination")),
+				map[string]interface{}{
+					"payment_hash": paymentHash,
+					"destination":  pay.Get("destination").String(),
+					"bolt11":       pay.Get("bolt11").String(),
+					"amount_msat":  int64(amount),
+				})
+		}
+	}
+	m.polled = true
+}
+
+// checkChannels reports channels which became normal, channels which
+// stopped being so, and peers disconnected for longer than disconnectAfter.
+func (m *Monitor) checkChannels(channels []PeerChannel) {
+	states := make(map[string]string)
+	connected := make(map[string]bool)
+	for _, channel := range channels {
+		id := channel.Get("channel_id").String()
+		state := channel.Get("state").String()
+		states[id] = state
+		connected[channel.peerID] = connected[channel.peerID] || channel.peerConnected
+
+		if !m.polled || state == m.channels[id] {
+			continue
+		}
+		data := map[string]interface{}{
+			"channel_id":       id,
+			"short_channel_id": channel.Get("short_channel_id").String(),
+			"peer_id":          channel.peerID,
+			"state":            state,
+		}
+		if state == "CHANNELD_NORMAL" {
+			capacity := msatField(channel.Result, "total_msat", "msatoshi_total")
+			data["amount_msat"] = int64(capacity)
+			m.publish(EventChannelOpened, "Channel opened",
+				fmt.Sprintf("%s with %s", capacity.Format(), channel.peerID), data)
+		} else if m.channels[id] == "CHANNELD_NORMAL" {
+			m.publish(EventChannelClosed, "Channel closed",
+				fmt.Sprintf("%s with %s is now %s", channel.Get("short_channel_id"), channel.peerID, state), data)
+		}
+	}
+	for id, state := range m.channels {
+		if _, exists := states[id]; !exists && state == "CHANNELD_NORMAL" {
+			m.publish(EventChannelClosed, "Channel closed", "Channel "+id+" is gone",
+				map[string]interface{}{"channel_id": id})
+		}
+	}
+	m.channels = states
+
+	now := time.Now()
+	for id, c := range connected {
+		if c {
+			delete(m.disconnected, id)
+			delete(m.reported, id)
+			continue
+		}
+		if _, exists := m.disconnected[id]; !exists {
+			m.disconnected[id] = now
+		}
+		since := m.disconnected[id]
+		if !m.reported[id] && now.Sub(since) >= m.disconnectAfter {
+			m.reported[id] = true
+			m.publish(EventPeerDisconnected, "Peer disconnected",
+				fmt.Sprintf("%s has been disconnected for %s", id, now.Sub(since).Round(time.Minute)),
+				map[string]interface{}{"peer_id": id, "since": since.Unix()})
+		}
+	}
+}
+
+// checkActive reports our channels which gossip says became inactive.
+func (m *Monitor) checkActive(active map[string]bool) {
+	for shortChannelID, a := range active {
+		if m.polled && !a && m.active[shortChannelID] {
+			m.publish(EventChannelInactive, "Channel inactive",
+				"Channel "+shortChannelID+" is no longer active",
+				map[string]interface{}{"short_channel_id": shortChannelID})
+		}
+	}
+	m.active = active
+}
+
+func (m *Monitor) publish(eventType, title, message string, data map[string]interface{}) {
+	events.Publish(NewEvent(eventType, title, message, data))
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"sort"
+)
+
+// maximum number of nodes displayed in the network table
+const networkTableSize = 500
+
+var networkSortOptions = []string{
+	"Capacity",
+	"Channels",
+	"Median fee rate",
+	"Centrality",
+	"Distance",
+	"Reachability gain",
+}
+
+var selectedNetworkSort = "Capacity"
+var networkHidePeers = true
+
+func sortGraphNodes(nodes []*GraphNode) {
+	sort.SliceStable(nodes, func(i, j int) bool {
+		n1 := nodes[i]
+		n2 := nodes[j]
+		switch selectedNetworkSort {
+		case "Channels":
+			return n1.channels > n2.channels
+		case "Median fee rate":
+			return n1.medianFee < n2.medianFee
+		case "Centrality":
+			return n1.centrality > n2.centrality
+		case "Distance":
+			// farthest first, unreachable nodes last
+			if n1.distance < 0 {
+				return false
+			}
+			if n2.distance < 0 {
+				return true
+			}
+			return n1.distance > n2.distance
+		case "Reachability gain":
+			return n1.reachGain > n2.reachGain
+		default:
+			return n1.capacity > n2.capacity
+		}
+	})
+}
+
+// visibleGraphNodes returns the top nodes of g for the current criteria.
+func visibleGraphNodes(g *Graph) []*GraphNode {
+	var nodes []*GraphNode
+	for id, gn := range g.nodes {
+		if id == g.localID || gn.channels == 0 || (networkHidePeers && gn.peer) {
+			continue
+		}
+		nodes = append(nodes, gn)
+	}
+	// stable order for equal values
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].node.id < nodes[j].node.id })
+	sortGraphNodes(nodes)
+	if len(nodes) > networkTableSize {
+		nodes = nodes[:networkTableSize]
+	}
+	return nodes
+}
+
+func fillNetworkTable(t *Table, rowOffset int, g *Graph) []*GraphNode {
+	for row := t.GetRowCount() - 1; row >= rowOffset; row-- {
+		t.RemoveRow(row)
+	}
+
+	nodes := visibleGraphNodes(g)
+	for idx, gn := range nodes {
+		var distance string
+		if gn.distance < 0 {
+			distance = "[red]unreachable"
+		} else {
+			distance = fmt.Sprintf("%d", gn.distance)
+		}
+		var reachGain string
+		if gn.reachGain > 0 {
+			reachGain = "[green]" + formatSats(gn.reachGain)
+		}
+		alias := gn.node.alias
+		if alias == "" {
+			alias = gn.node.id
+		}
+		aliasColor := "[greenyellow]"
+		if gn.peer {
+			aliasColor = "[darkviolet]"
+		}
+
+		currentRow := idx + rowOffset
+		t.SetCell(currentRow, 0,
+			tview.NewTableCell("[yellow]"+formatSats(gn.capacity)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 1,
+			tview.NewTableCell(formatSats(gn.channels)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 2,
+			tview.NewTableCell("[lightyellow]"+formatSats(gn.medianFee)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 3,
+			tview.NewTableCell(fmt.Sprintf("%.3f", gn.centrality)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 4,
+			tview.NewTableCell(distance).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 5,
+			tview.NewTableCell(reachGain).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 6,
+			tview.NewTableCell(aliasColor+alias))
+	}
+	t.Select(rowOffset, 0)
+	return nodes
+}
+
+func networkPage(ui *UI) tview.Primitive {
+	g, err := getGraph(ui)
+	if err != nil {
+		return ui.NewErrorPage("network", err)
+	}
+
+	t := NewTable()
+	t.SetTitle(" Network ")
+	t.SetBorder(true)
+	t.SetBorderColor(BorderColor)
+	t.SetSelectable(true, false)
+
+	t.AddColumnHeader("\n[bold]capacity\n(sats)", tview.AlignRight)
+	t.AddColumnHeader("\nchannels", tview.AlignRight)
+	t.AddColumnHeader("median\nfee_rate\n(ppm)", tview.AlignRight)
+	t.AddColumnHeader("\ncentrality", tview.AlignRight)
+	t.AddColumnHeader("\ndistance\n(hops)", tview.AlignRight)
+	t.AddColumnHeader("reachability\ngain\n(nodes)", tview.AlignRight)
+	t.AddColumnHeader("\nalias", tview.AlignLeft)
+	t.Separator(11)
+	rowOffset := t.GetRowCount()
+	t.SetFixed(rowOffset, 0)
+
+	t.SetDoneFunc(func(key tcell.Key) {
+		ui.pages.SwitchToPage("dash")
+		ui.FocusMenu()
+	})
+
+	// Do not allow to select the header
+	t.SetSelectionChangedFunc(func(row, column int) {
+		if row < rowOffset {
+			t.Select(row+1, column)
+		}
+	})
+
+	nodes := fillNetworkTable(t, rowOffset, g)
+	t.SetExport(ui, "network", func() Export {
+		return networkExport(nodes)
+	})
+
+	openChannel := func() {
+		currentRow, _ := t.GetSelection()
+		if currentRow < rowOffset || currentRow-rowOffset >= len(nodes) {
+			return
+		}
+		if ui.HasPage("openChannel") {
+			ui.DeletePage("openChannel")
+		}
+		nodeID := nodes[currentRow-rowOffset].node.id
+		ui.LoadModal("openChannel", func(ui *UI) tview.Primitive {
+			return ui.NewOpenChannelPage(nodeID, "network")
+		})
+	}
+	t.SetSelectedFunc(func(row, column int) {
+		openChannel()
+	})
+
+	form := tview.NewForm()
+	form.SetHorizontal(true)
+	form.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Rank nodes (s) ")
+	initialSort := 0
+	for idx, option := range networkSortOptions {
+		if option == selectedNetworkSort {
+			initialSort = idx
+			break
+		}
+	}
+	form.AddDropDown("Sort by", networkSortOptions, initialSort, nil)
+	form.AddCheckbox("Hide peers", networkHidePeers, nil)
+	form.AddButton("Apply", func() {
+		_, selectedNetworkSort = form.GetFormItemByLabel("Sort by").(*tview.DropDown).GetCurrentOption()
+		networkHidePeers = form.GetFormItemByLabel("Hide peers").(*tview.Checkbox).IsChecked()
+		nodes = fillNetworkTable(t, rowOffset, g)
+		ui.app.SetFocus(t)
+	})
+	form.SetCancelFunc(func() {
+		ui.app.SetFocus(t)
+	})
+
+	// Keyboard handler
+	t.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'o':
+			openChannel()
+		case 's':
+			ui.app.SetFocus(form)
+		case 'h':
+			help := []string{
+				"j/k   - Scroll down/up              ",
+				"G/g   - Scroll to bottom/top        ",
+				"Enter - Open channel with node      ",
+				"o     - Open channel with node      ",
+				"s     - Rank nodes by               ",
+				"E     - Export table to CSV/JSON    ",
+				"h     - Toggle help                 ",
+				"ESC   - Focus menu pane             ",
+			}
+			if ui.HasPage("help") {
+				ui.DeletePage("help")
+			} else {
+				ui.AddPage("help", ui.NewHelpPage(help), true, true)
+			}
+		}
+		return event
+	})
+
+	info := tview.NewTextView()
+	info.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Graph ")
+	info.SetDynamicColors(true)
+	reachable := 0
+	for _, gn := range g.nodes {
+		if gn.distance > 0 {
+			reachable += 1
+		}
+	}
+	info.SetText(fmt.Sprintf("[deepskyblue]Nodes: [white]%s  [deepskyblue]Reachable from us: [white]%s  [deepskyblue]Our peers: [white]%d",
+		formatSats(int64(len(g.nodes))), formatSats(int64(reachable)), len(g.neighbors[g.localID])))
+
+	page := tview.NewFlex()
+	page.SetDirection(tview.FlexRow)
+	page.AddItem(info, 3, 0, false)
+	page.AddItem(form, 3, 0, false)
+	page.AddItem(t, 0, 1, true)
+	return page
+}
@@ -1,10 +1,10 @@
 package main
 
 type OptionWillFund struct {
-	leaseFeeBaseMsat                     int64
+	leaseFeeBaseMsat                     Msat
 	leaseFeeBasis                        int64
 	fundingWeight                        int64
-	channelFeeMaxBaseMsat                int64
+	channelFeeMaxBaseMsat                Msat
 	channelFeeMaxProportionalThousandths int64
 	compactLease                         string
 }
@@ -13,5 +13,12 @@ type Node struct {
 	alias          string
 	color          string
 	blockheight    int64
+	features       string
+	addresses      []string
 	optionWillFund *OptionWillFund
 }
+
+type NodeStats struct {
+	channels int64
+	capacity int64
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"io/ioutil"
+	"net/http"
+	"net/smtp"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// how long notifiers may take to deliver an event
+const notifyTimeout = 10 * time.Second
+
+// how long toasts stay on screen
+const toastDuration = 5 * time.Second
+
+// Notifier delivers events outside of cluster.
+type Notifier interface {
+	Name() string
+	Notify(e Event) error
+}
+
+// BellNotifier rings the terminal bell.
+type BellNotifier struct{}
+
+func (n BellNotifier) Name() string {
+	return "bell"
+}
+
+func (n BellNotifier) Notify(e Event) error {
+	_, err := fmt.Fprint(os.Stderr, "\a")
+	return err
+}
+
+// CommandNotifier runs a shell command, e.g. notify-send for desktop
+// notifications. The event is in the CLUSTER_EVENT_* environment variables
+// and, as JSON, on stdin.
+type CommandNotifier struct {
+	command string
+}
+
+func (n CommandNotifier) Name() string {
+	return "command"
+}
+
+func (n CommandNotifier) Notify(e Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+
+	if output, err := runEventCommand(ctx, n.command, e); err != nil {
+		return fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// runEventCommand runs command with sh, passing it e in the CLUSTER_EVENT_*
+// environment variables and as JSON on stdin, and returns what it printed.
+func runEventCommand(ctx context.Context, command string, e Event) ([]byte, error) {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.CommandContext(ctx, "sh", "-c", command)
+	cmd.Env = append(os.Environ(),
+		"CLUSTER_EVENT_TYPE="+e.Type,
+		"CLUSTER_EVENT_TITLE="+e.Title,
+		"CLUSTER_EVENT_MESSAGE="+e.Message)
+	cmd.Stdin = bytes.NewReader(payload)
+
+	// with a pipe, commands which started others in the background would
+	// hold cmd.Wait until those are done too, timed out or not
+	f, err := ioutil.TempFile("", "cluster-command")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	cmd.Stdout = f
+	cmd.Stderr = f
+
+	err = cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		err = ctx.Err()
+	}
+	output, _ := ioutil.ReadFile(f.Name())
+	return output, err
+}
+
+// WebhookNotifier posts events as JSON to a URL.
+type WebhookNotifier struct {
+	url string
+}
+
+func (n WebhookNotifier) Name() string {
+	return "webhook"
+}
+
+func (n WebhookNotifier) Notify(e Event) error {
+	payload, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	client := &http.Client{Timeout: notifyTimeout}
+	resp, err := client.Post(n.url, "application/json", bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s replied %s", n.url, resp.Status)
+	}
+	return nil
+}
+
+// SMTPNotifier mails events. The password is read from the
+// CLUSTER_SMTP_PASSWORD environment variable rather than the command line.
+type SMTPNotifier struct {
+	addr string
+	from string
+	to   []string
+	user string
+}
+
+func (n SMTPNotifier) Name() string {
+	return "smtp"
+}
+
+func (n SMTPNotifier) Notify(e Event) error {
+	var auth smtp.Auth
+	if n.user != "" {
+		host := strings.Split(n.addr, ":")[0]
+		auth = smtp.PlainAuth("", n.user, os.Getenv("CLUSTER_SMTP_PASSWORD"), host)
+	}
+	message := "From: " + n.from + "\r\n" +
+		"To: " + strings.Join(n.to, ", ") + "\r\n" +
+		"Subject: [cluster] " + e.Title + "\r\n" +
+		"Date: " + e.Time.Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n" +
+		"\r\n" +
+		e.Message + "\r\n"
+	if e.Data != nil {
+		data, _ := json.MarshalIndent(e.Data, "", "  ")
+		message += "\r\n" + strings.ReplaceAll(string(data), "\n", "\r\n") + "\r\n"
+	}
+	return smtp.SendMail(n.addr, auth, n.from, n.to, []byte(message))
+}
+
+// SendTestNotification sends a test event to every notifier, to check they
+// are set up right.
+func SendTestNotification() error {
+	if len(notifiers) == 0 {
+		return fmt.Errorf("no notifiers set up")
+	}
+	e := NewEvent(EventTest, "Test notification", "Notifications from cluster work", nil)
+	failed := false
+	for _, n := range notifiers {
+		if err := n.Notify(e); err != nil {
+			fmt.Fprintf(os.Stderr, "%s: %s\n", n.Name(), err)
+			failed = true
+		} else {
+			fmt.Fprintf(os.Stderr, "%s: ok\n", n.Name())
+		}
+	}
+	if failed {
+		return fmt.Errorf("some notifiers failed")
+	}
+	return nil
+}
+
+// notifiers are the sinks events are delivered to, besides the activity
+// log and toasts.
+var notifiers []Notifier
+
+// forwardNotifyMin is the smallest forward the activity log, toasts and
+// notifiers tell about, hooks get every forward.
+var forwardNotifyMin Msat
+
+// notified tells whether e is worth notifying.
+func notified(e Event) bool {
+	if e.Type == EventForwardSettled {
+		out, _ := e.Data["out_msat"].(int64)
+		return Msat(out) >= forwardNotifyMin
+	}
+	return true
+}
+
+// StartNotifiers delivers the events of the bus to the notifiers, logging
+// those which fail.
+func (ui *UI) StartNotifiers() {
+	for _, n := range notifiers {
+		n := n
+		events.Subscribe(func(e Event) {
+			if !notified(e) {
+				return
+			}
+			if err := n.Notify(e); err != nil {
+				ui.log.Warn(fmt.Sprintf("Can't notify %s with %s: %s\n", e.Type, n.Name(), err))
+			}
+		})
+	}
+}
+
+// overlay holds the layout and, on top of it, the toasts.
+var overlay *tview.Pages
+var toastID = 0
+
+// StartToasts shows events in the activity log and as toasts.
+func (ui *UI) StartToasts() {
+	events.Subscribe(func(e Event) {
+		if !notified(e) {
+			return
+		}
+		ui.app.QueueUpdateDraw(func() {
+			if e.Warning() {
+				ui.log.Warn(e.Title + ": " + e.Message + "\n")
+			} else {
+				ui.log.Ok(e.Title + ": " + e.Message + "\n")
+			}
+			ui.Toast(e)
+		})
+	})
+}
+
+// Toast shows e in the top right corner for a few seconds, without taking
+// the focus.
+func (ui *UI) Toast(e Event) {
+	if overlay == nil {
+		return
+	}
+	tv := tview.NewTextView()
+	tv.SetBorder(true)
+	tv.SetBorderColor(BorderColor)
+	if e.Warning() {
+		tv.SetBorderColor(tcell.ColorRed)
+	}
+	tv.SetTitle(" " + e.Title + " ")
+	tv.SetDynamicColors(true)
+	tv.SetWordWrap(true)
+	tv.SetText(tview.Escape(e.Message))
+
+	toast := tview.NewGrid().
+		SetColumns(0, 50, 1).
+		SetRows(1, 5, 0).
+		AddItem(tv, 1, 1, 1, 1, 0, 0, false)
+
+	// pages focus the page on top as they change
+	focus := ui.app.GetFocus()
+	overlay.AddPage("toast", toast, true, true)
+	ui.app.SetFocus(focus)
+
+	toastID += 1
+	id := toastID
+	go func() {
+		time.Sleep(toastDuration)
+		ui.app.QueueUpdateDraw(func() {
+			if toastID != id {
+				return
+			}
+			focus := ui.app.GetFocus()
+			overlay.RemovePage("toast")
+			ui.app.SetFocus(focus)
+		})
+	}()
+}
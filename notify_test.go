@@ -0,0 +1,224 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testEvent is a settled forward, with the data webhooks and mails carry.
+func testEvent() Event {
+	return Event{
+		Type:    EventForwardSettled,
+		Time:    time.Date(2023, 7, 22, 10, 0, 0, 0, time.UTC),
+		Title:   "Forward settled",
+		Message: "Earned 26 sats forwarding 100,000 sats",
+		Data: map[string]interface{}{
+			"in_channel":  "781500x340x0",
+			"out_channel": "780000x1200x1",
+			"fee_msat":    26000,
+		},
+	}
+}
+
+func TestWebhookNotifier(t *testing.T) {
+	var method, contentType string
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		contentType = r.Header.Get("Content-Type")
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	e := testEvent()
+	if err := (WebhookNotifier{server.URL}).Notify(e); err != nil {
+		t.Fatal(err)
+	}
+	if method != http.MethodPost {
+		t.Errorf("method %s, expected POST", method)
+	}
+	if contentType != "application/json" {
+		t.Errorf("Content-Type %q, expected application/json", contentType)
+	}
+	var got struct {
+		Type    string                 `json:"type"`
+		Time    time.Time              `json:"time"`
+		Title   string                 `json:"title"`
+		Message string                 `json:"message"`
+		Data    map[string]interface{} `json:"data"`
+	}
+	if err := json.Unmarshal(body, &got); err != nil {
+		t.Fatalf("payload %s: %s", body, err)
+	}
+	if got.Type != e.Type || !got.Time.Equal(e.Time) || got.Title != e.Title || got.Message != e.Message {
+		t.Errorf("payload %s, expected the event %+v", body, e)
+	}
+	if got.Data["in_channel"] != "781500x340x0" || got.Data["fee_msat"] != float64(26000) {
+		t.Errorf("data %v, expected %v", got.Data, e.Data)
+	}
+}
+
+func TestWebhookNotifierError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	err := (WebhookNotifier{server.URL}).Notify(testEvent())
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("error %v, expected the 503 status", err)
+	}
+}
+
+// smtpMail is what a client sent to the SMTP stub.
+type smtpMail struct {
+	from string
+	to   []string
+	data string
+}
+
+// startSMTPStub accepts a single mail on a local port, without
+// authentication, and sends it on the returned channel.
+func startSMTPStub(t *testing.T) (string, <-chan smtpMail) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	mails := make(chan smtpMail, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+		text := textproto.NewConn(conn)
+		var mail smtpMail
+		text.PrintfLine("220 localhost stub")
+		for {
+			line, err := text.ReadLine()
+			if err != nil {
+				return
+			}
+			command := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+			switch command {
+			case "EHLO", "HELO":
+				text.PrintfLine("250 localhost")
+			case "MAIL":
+				mail.from = strings.TrimSuffix(strings.TrimPrefix(line[len("MAIL FROM:"):], "<"), ">")
+				text.PrintfLine("250 OK")
+			case "RCPT":
+				mail.to = append(mail.to, strings.TrimSuffix(strings.TrimPrefix(line[len("RCPT TO:"):], "<"), ">"))
+				text.PrintfLine("250 OK")
+			case "DATA":
+				text.PrintfLine("354 go ahead")
+				data, err := ioutil.ReadAll(text.DotReader())
+				if err != nil {
+					return
+				}
+				mail.data = string(data)
+				text.PrintfLine("250 OK")
+			case "QUIT":
+				text.PrintfLine("221 bye")
+				mails <- mail
+				return
+			default:
+				text.PrintfLine("502 not implemented")
+			}
+		}
+	}()
+	return listener.Addr().String(), mails
+}
+
+func TestSMTPNotifier(t *testing.T) {
+	addr, mails := startSMTPStub(t)
+	n := SMTPNotifier{
+		addr: addr,
+		from: "cluster@example.com",
+		to:   []string{"alice@example.com", "bob@example.com"},
+	}
+	e := testEvent()
+	if err := n.Notify(e); err != nil {
+		t.Fatal(err)
+	}
+
+	var mail smtpMail
+	select {
+	case mail = <-mails:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no mail received")
+	}
+	if mail.from != n.from {
+		t.Errorf("MAIL FROM %q, expected %q", mail.from, n.from)
+	}
+	if strings.Join(mail.to, ",") != strings.Join(n.to, ",") {
+		t.Errorf("RCPT TO %v, expected %v", mail.to, n.to)
+	}
+
+	// the dot reader turns the line endings into \n
+	parts := strings.SplitN(mail.data, "\n\n", 2)
+	if len(parts) != 2 {
+		t.Fatalf("no headers and body in %q", mail.data)
+	}
+	headers := make(map[string]string)
+	for _, line := range strings.Split(parts[0], "\n") {
+		kv := strings.SplitN(line, ": ", 2)
+		if len(kv) != 2 {
+			t.Fatalf("malformed header %q", line)
+		}
+		headers[kv[0]] = kv[1]
+	}
+	wantHeaders := map[string]string{
+		"From":         "cluster@example.com",
+		"To":           "alice@example.com, bob@example.com",
+		"Subject":      "[cluster] Forward settled",
+		"Date":         "Sat, 22 Jul 2023 10:00:00 +0000",
+		"Content-Type": "text/plain; charset=utf-8",
+	}
+	for name, want := range wantHeaders {
+		if headers[name] != want {
+			t.Errorf("%s header %q, expected %q", name, headers[name], want)
+		}
+	}
+
+	body := parts[1]
+	if !strings.HasPrefix(body, e.Message+"\n") {
+		t.Errorf("body %q doesn't start with the message", body)
+	}
+	var data map[string]interface{}
+	if err := json.Unmarshal([]byte(strings.TrimPrefix(body, e.Message+"\n")), &data); err != nil {
+		t.Fatalf("no data in body %q: %s", body, err)
+	}
+	if data["out_channel"] != "780000x1200x1" || data["fee_msat"] != float64(26000) {
+		t.Errorf("data %v, expected %v", data, e.Data)
+	}
+}
+
+func TestNotified(t *testing.T) {
+	defer func(min Msat) { forwardNotifyMin = min }(forwardNotifyMin)
+	forwardNotifyMin = Sats(10000)
+
+	forward := func(out Msat) Event {
+		return NewEvent(EventForwardSettled, "Forward settled", "", map[string]interface{}{"out_msat": int64(out)})
+	}
+	if notified(forward(Sats(9999))) {
+		t.Error("forward below --notify-forward-min notified")
+	}
+	if !notified(forward(Sats(10000))) {
+		t.Error("forward of --notify-forward-min not notified")
+	}
+	if !notified(NewEvent(EventInvoicePaid, "Invoice paid", "", nil)) {
+		t.Error("paid invoice not notified")
+	}
+}
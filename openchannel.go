@@ -0,0 +1,430 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Channels bigger than this require option_support_large_channel on both ends
+const maxStandardChannelSize = 16777215
+
+type ChannelOpen struct {
+	node     Node
+	amount   int64
+	pushMsat Msat
+	closeTo  string
+	announce bool
+}
+
+// channels queued to be opened in a single transaction, emptied when the
+// page is opened or cancelled
+var openQueue []ChannelOpen
+
+var feerateOptions = []string{
+	"slow",
+	"normal",
+	"urgent",
+	"custom",
+}
+
+// feeratePerKb maps the named feerates used by lightningd to the estimates
+// reported by the feerates command.
+func feeratePerKb(rates gjson.Result, feerate string) int64 {
+	switch feerate {
+	case "slow":
+		return rates.Get("perkb.mutual_close").Int()
+	case "urgent":
+		return rates.Get("perkb.unilateral_close").Int()
+	default:
+		return rates.Get("perkb.opening").Int()
+	}
+}
+
+// estimateFundingFee estimates the fee (sats) of a transaction funding
+// outputs channels for a total of amount sats, spending the biggest utxos
+// first and returning the change to the wallet.
+func estimateFundingFee(utxos []int64, amount int64, outputs int, perKb int64) (int64, error) {
+	sorted := append([]int64(nil), utxos...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] > sorted[j] })
+
+	// version, locktime, segwit marker, change output and channel outputs
+	vbytes := int64(11 + 31 + 43*outputs)
+	inputs := int64(0)
+	for _, utxo := range sorted {
+		inputs += utxo
+		vbytes += 68
+		fee := FeeratePerKb(perKb).Fee(vbytes).Sat()
+		if inputs >= amount+fee {
+			return fee, nil
+		}
+	}
+	return FeeratePerKb(perKb).Fee(vbytes).Sat(), errors.New("insufficient funds")
+}
+
+func getAvailableUtxos(ui *UI) ([]int64, error) {
+	var utxos []int64
+	funds, err := listFunds(ui, false)
+	if err != nil {
+		return nil, err
+	}
+	for _, utxo := range funds.Get("outputs").Array() {
+		if utxo.Get("status").String() == "confirmed" && !utxo.Get("reserved").Bool() {
+			utxos = append(utxos, satOrMsatField(utxo, "amount_msat", "value").Sat())
+		}
+	}
+	return utxos, nil
+}
+
+// aliasIDRe matches the "alias (id)" entries of the node autocomplete lists.
+var aliasIDRe = regexp.MustCompile(`\(([a-z0-9]+)\)$`)
+
+// parseNodeID extracts the node id from "alias (id)", "id@host:port" or "id".
+func parseNodeID(text string) string {
+	matches := aliasIDRe.FindStringSubmatch(strings.TrimSpace(text))
+	if len(matches) == 2 {
+		return matches[1]
+	}
+	return strings.Split(strings.TrimSpace(text), "@")[0]
+}
+
+// validateChannelOpen checks a channel against our own limits and the
+// remote node requirements.
+func validateChannelOpen(open ChannelOpen, config gjson.Result, peers gjson.Result) error {
+	if len(open.node.id) != 66 {
+		return errors.New("unknown node " + open.node.id)
+	}
+	for _, queued := range openQueue {
+		if queued.node.id == open.node.id {
+			return errors.New("a channel with " + open.node.alias + " is already queued")
+		}
+	}
+	if minCapacity := config.Get("min-capacity-sat").Int(); open.amount < minCapacity {
+		return fmt.Errorf("channel size is below min-capacity-sat (%s sats)", formatSats(minCapacity))
+	}
+	if open.pushMsat > Sats(open.amount) {
+		return errors.New("push amount is bigger than the channel")
+	}
+	if open.amount > maxStandardChannelSize {
+		features := open.node.features
+		for _, peer := range peers.Get("peers").Array() {
+			if peer.Get("id").String() == open.node.id && peer.Get("features").String() != "" {
+				features = peer.Get("features").String()
+			}
+		}
+		if !config.Get("large-channels").Bool() {
+			return errors.New("large channels are not enabled on this node")
+		}
+		if !hasFeature(features, 18) {
+			return errors.New(open.node.alias + " does not support large channels")
+		}
+	}
+	if len(open.node.addresses) == 0 && !isPeer(peers, open.node.id) {
+		return errors.New(open.node.alias + " has no known address, connect to it first")
+	}
+	return nil
+}
+
+func isPeer(peers gjson.Result, id string) bool {
+	for _, peer := range peers.Get("peers").Array() {
+		if peer.Get("id").String() == id {
+			return true
+		}
+	}
+	return false
+}
+
+func isConnected(peers gjson.Result, id string) bool {
+	for _, peer := range peers.Get("peers").Array() {
+		if peer.Get("id").String() == id {
+			return peer.Get("connected").Bool()
+		}
+	}
+	return false
+}
+
+// NewOpenChannelPage queues channels, starting with nodeID (may be empty), to
+// be opened in a single transaction and gives the focus back to the parent
+// page when done. It calls lightningd, load it with LoadModal.
+func (ui *UI) NewOpenChannelPage(nodeID string, parent string) tview.Primitive {
+	openQueue = nil
+
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Open new channels ")
+	form.SetBorderColor(BorderColor)
+
+	queueView := tview.NewTextView()
+	queueView.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Batch ")
+	queueView.SetDynamicColors(true)
+
+	config, err := getConfig(ui)
+	if err != nil {
+		return ui.Modal(ui.NewErrorPage(parent, err), 80, 20)
+	}
+	rates, err := getFeerates(ui)
+	if err != nil {
+		return ui.Modal(ui.NewErrorPage(parent, err), 80, 20)
+	}
+	utxos, err := getAvailableUtxos(ui)
+	if err != nil {
+		return ui.Modal(ui.NewErrorPage(parent, err), 80, 20)
+	}
+	var availableFunds int64
+	for _, utxo := range utxos {
+		availableFunds += utxo
+	}
+
+	form.AddInputField("Node Alias (ID)", "", 70, nil, nil)
+	form.AddInputField("Available funds (sats)", fmt.Sprintf("%d", availableFunds), 70, func(text string, lastChar rune) bool { return false }, nil)
+	form.AddInputField("Channel size (sats)", "", 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Push (msat)", "", 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Close to address", "", 70, nil, nil)
+	form.AddCheckbox("Announce", true, nil)
+	form.AddDropDown("Feerate", feerateOptions, 1, nil)
+	form.AddInputField("Custom feerate (sat/vB)", "", 20, tview.InputFieldInteger, nil)
+
+	searchAlias := form.GetFormItemByLabel("Node Alias (ID)").(*tview.InputField)
+	if nodeID != "" {
+		node := listNode(ui, nodeID)
+		searchAlias.SetText(fmt.Sprintf("%s (%s)", node.alias, nodeID))
+	}
+	searchAlias.SetAutocompleteFunc(func(currentText string) (entries []string) {
+
+		if len(currentText) < 2 {
+			return
+		}
+		nodes := listNodesByAliasOrID(ui, currentText)
+		if len(nodes) > 0 {
+			for _, node := range nodes {
+				entries = append(entries, fmt.Sprintf("%s (%s)", node.alias, node.id))
+			}
+			if len(entries) == 0 {
+				entries = nil
+			}
+		}
+		return
+	})
+
+	// selectedFeerate returns the feerate to pass to lightningd and the
+	// equivalent in sat/kvB used for the fee preview.
+	selectedFeerate := func() (string, int64, error) {
+		_, feerate := form.GetFormItemByLabel("Feerate").(*tview.DropDown).GetCurrentOption()
+		if feerate != "custom" {
+			return feerate, feeratePerKb(rates, feerate), nil
+		}
+		customField := form.GetFormItemByLabel("Custom feerate (sat/vB)").(*tview.InputField)
+		satPerVbyte, err := strconv.ParseInt(customField.GetText(), 10, 64)
+		if err != nil || satPerVbyte <= 0 {
+			return "", 0, errors.New("incorrect custom feerate " + customField.GetText())
+		}
+		return fmt.Sprintf("%dperkb", satPerVbyte*1000), satPerVbyte * 1000, nil
+	}
+
+	// preview returns the sum of the queued channels and the fee estimate
+	preview := func() (int64, int64, error) {
+		total := int64(0)
+		for _, open := range openQueue {
+			total += open.amount
+		}
+		if len(openQueue) == 0 {
+			return 0, 0, nil
+		}
+		_, perKb, err := selectedFeerate()
+		if err != nil {
+			return total, 0, err
+		}
+		fee, err := estimateFundingFee(utxos, total, len(openQueue), perKb)
+		return total, fee, err
+	}
+
+	showQueue := func() {
+		queueView.Clear()
+		for idx, open := range openQueue {
+			fmt.Fprintf(queueView, "[white]%d. [greenyellow]%s\n", idx+1, open.node.alias)
+			fmt.Fprintf(queueView, "   [yellow]%s [white]sats", formatSats(open.amount))
+			if open.pushMsat > 0 {
+				fmt.Fprintf(queueView, ", push [yellow]%s [white]msat", formatSats(int64(open.pushMsat)))
+			}
+			if !open.announce {
+				fmt.Fprint(queueView, ", [grey]private")
+			}
+			fmt.Fprint(queueView, "\n")
+		}
+		total, fee, err := preview()
+		fmt.Fprintf(queueView, "\n[deepskyblue]Channels: [yellow]%s [white]sats\n", formatSats(total))
+		fmt.Fprintf(queueView, "[deepskyblue]Estimated fee: [yellow]%s [white]sats\n", formatSats(fee))
+		fmt.Fprintf(queueView, "[deepskyblue]Remaining: [yellow]%s [white]sats\n", formatSats(availableFunds-total-fee))
+		if err != nil {
+			fmt.Fprintf(queueView, "[red]%s\n", err.Error())
+		}
+	}
+	form.GetFormItemByLabel("Feerate").(*tview.DropDown).SetSelectedFunc(func(text string, index int) {
+		showQueue()
+	})
+	form.GetFormItemByLabel("Custom feerate (sat/vB)").(*tview.InputField).SetChangedFunc(func(text string) {
+		showQueue()
+	})
+
+	form.AddButton("Add to batch", func() {
+		id := parseNodeID(searchAlias.GetText())
+		node := listNode(ui, id)
+		if node.id == "" {
+			node.id = id
+		}
+		if node.alias == "" {
+			node.alias = id
+		}
+
+		channelSizeField := form.GetFormItemByLabel("Channel size (sats)").(*tview.InputField)
+		channelSize, err := strconv.ParseInt(channelSizeField.GetText(), 10, 64)
+		if err != nil {
+			ui.log.Warn(fmt.Sprintf("Incorrect channel size: %s\n", channelSizeField.GetText()))
+			return
+		}
+		pushField := form.GetFormItemByLabel("Push (msat)").(*tview.InputField)
+		pushMsat := Msat(0)
+		if pushField.GetText() != "" {
+			pushMsat, err = ParseMsat(pushField.GetText())
+			if err != nil {
+				ui.log.Warn(fmt.Sprintf("Incorrect push amount: %s\n", pushField.GetText()))
+				return
+			}
+		}
+
+		open := ChannelOpen{
+			node:     node,
+			amount:   channelSize,
+			pushMsat: pushMsat,
+			closeTo:  strings.TrimSpace(form.GetFormItemByLabel("Close to address").(*tview.InputField).GetText()),
+			announce: form.GetFormItemByLabel("Announce").(*tview.Checkbox).IsChecked(),
+		}
+		peers, err := listPeers(ui)
+		if err != nil {
+			ui.log.Warn("Cannot open channel: " + err.Error() + "\n")
+			return
+		}
+		if err := validateChannelOpen(open, config, peers); err != nil {
+			ui.log.Warn("Cannot open channel: " + err.Error() + "\n")
+			return
+		}
+		openQueue = append(openQueue, open)
+		searchAlias.SetText("")
+		channelSizeField.SetText("")
+		pushField.SetText("")
+		showQueue()
+	})
+	form.AddButton("Remove last", func() {
+		if len(openQueue) > 0 {
+			openQueue = openQueue[:len(openQueue)-1]
+		}
+		showQueue()
+	})
+	connecting := false
+	form.AddButton("Open channels", func() {
+		if connecting {
+			ui.log.Warn("Still connecting to the peers\n")
+			return
+		}
+		if len(openQueue) == 0 {
+			ui.log.Warn("No channels queued, add at least one to the batch\n")
+			return
+		}
+		feerate, _, err := selectedFeerate()
+		if err != nil {
+			ui.log.Warn(err.Error() + "\n")
+			return
+		}
+		total, fee, err := preview()
+		if err != nil || total+fee > availableFunds {
+			ui.log.Warn(fmt.Sprintf("Insufficient funds: %s sats needed, %s sats available\n", formatSats(total+fee), formatSats(availableFunds)))
+			return
+		}
+
+		// connect to the peers first
+		peers, err := listPeers(ui)
+		if err != nil {
+			ui.log.Warn("Cannot open channels: " + err.Error() + "\n")
+			return
+		}
+		var destinations []map[string]interface{}
+		var unconnected []Node
+		for _, open := range openQueue {
+			if !isConnected(peers, open.node.id) {
+				unconnected = append(unconnected, open.node)
+			}
+			destination := map[string]interface{}{
+				"id":       open.node.id,
+				"amount":   fmt.Sprintf("%dsat", open.amount),
+				"announce": open.announce,
+			}
+			if open.pushMsat > 0 {
+				destination["push_msat"] = open.pushMsat
+			}
+			if open.closeTo != "" {
+				destination["close_to"] = open.closeTo
+			}
+			destinations = append(destinations, destination)
+		}
+
+		connecting = true
+		connectPeers(ui, unconnected, func(err error) {
+			connecting = false
+			if err != nil {
+				ui.log.Warn("Cannot open channels: " + err.Error() + "\n")
+				return
+			}
+			ui.log.Info(fmt.Sprintf("Opening %d channels, total: %d sats, feerate: %s\n", len(destinations), total, feerate))
+			openQueue = nil
+			ui.DeletePage("openChannel")
+			ui.SetFocus(parent)
+			multiFundChannel(ui, destinations, feerate, func(response gjson.Result, err error) {
+				if err != nil {
+					ui.log.Warn(fmt.Sprintf("Error when opening channels: %s\n", err))
+					return
+				}
+				ui.log.Ok("Funding transaction " + response.Get("txid").String() + "\n")
+				for _, failed := range response.Get("failed").Array() {
+					ui.log.Warn(fmt.Sprintf("Failed to open channel with %s: %s\n", failed.Get("id").String(), failed.Get("error.message").String()))
+				}
+			})
+		})
+	})
+	cancel := func() {
+		openQueue = nil
+		ui.pages.HidePage("openChannel")
+		ui.SetFocus(parent)
+	}
+	form.AddButton("Cancel", cancel)
+	form.SetCancelFunc(cancel)
+
+	showQueue()
+
+	flex := tview.NewFlex().SetDirection(tview.FlexColumn).
+		AddItem(form, 0, 2, true).
+		AddItem(queueView, 0, 1, false)
+
+	return ui.Modal(flex, 140, 24)
+}
+
+// connectPeers connects to nodes one after the other in the background, and
+// calls done once connected to all of them or at the first failure.
+func connectPeers(ui *UI, nodes []Node, done func(error)) {
+	if len(nodes) == 0 {
+		done(nil)
+		return
+	}
+	ui.log.Info("Connecting to " + nodes[0].alias + "\n")
+	connect(ui, nodes[0].id, func(_ gjson.Result, err error) {
+		if err != nil {
+			done(fmt.Errorf("could not connect to %s: %s", nodes[0].alias, err))
+			return
+		}
+		connectPeers(ui, nodes[1:], done)
+	})
+}
@@ -1,12 +1,90 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	decodepay "github.com/fiatjaf/ln-decodepay"
+	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"strconv"
+	"strings"
 )
 
-func payPage(ui *UI) *Form {
+// payment modes of the Pay page
+var payModes = []string{"Invoice", "Keysend"}
+
+// TLV record wallets read the message of keysend payments from
+const keysendMessageType = "34349334"
+
+// keysendLabel labels the keysend payments made from cluster, followed by
+// their message if any, so the activities can tell them from invoice
+// payments.
+const keysendLabel = "cluster keysend"
+
+// keysendPaymentLabel is the label of a keysend payment with message.
+func keysendPaymentLabel(message string) string {
+	if message == "" {
+		return keysendLabel
+	}
+	return keysendLabel + ": " + message
+}
+
+// keysendMessage returns the message of a keysend payment labelled with
+// keysendPaymentLabel, and false for other payments.
+func keysendMessage(label string) (string, bool) {
+	if label == keysendLabel {
+		return "", true
+	}
+	if strings.HasPrefix(label, keysendLabel+": ") {
+		return strings.TrimPrefix(label, keysendLabel+": "), true
+	}
+	return "", false
+}
+
+// payPage pays an invoice or, in keysend mode, a node directly.
+func payPage(ui *UI) tview.Primitive {
+	modes := tview.NewPages()
+	invoiceForm := payInvoiceForm(ui)
+	keysendForm, keysendView := keysendPage(ui)
+	modes.AddPage("Invoice", invoiceForm, true, true)
+	modes.AddPage("Keysend", keysendView, true, false)
+
+	mode := tview.NewDropDown().SetLabel("Mode ")
+	mode.SetOptions(payModes, func(option string, index int) {
+		modes.SwitchToPage(option)
+	})
+	mode.SetCurrentOption(0)
+	mode.SetDoneFunc(func(key tcell.Key) {
+		switch key {
+		case tcell.KeyEscape:
+			ui.FocusMenu()
+		case tcell.KeyTab:
+			if name, _ := modes.GetFrontPage(); name == "Keysend" {
+				ui.app.SetFocus(keysendForm)
+			} else {
+				ui.app.SetFocus(invoiceForm)
+			}
+		}
+	})
+	backToMode := func() {
+		ui.app.SetFocus(mode)
+	}
+	invoiceForm.SetCancelFunc(backToMode)
+	keysendForm.SetCancelFunc(backToMode)
+
+	modeBox := tview.NewFlex()
+	modeBox.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Pay ")
+	modeBox.AddItem(mode, 0, 1, true)
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(modeBox, 3, 0, true)
+	layout.AddItem(modes, 0, 1, false)
+	return layout
+}
+
+func payInvoiceForm(ui *UI) *Form {
 	f := NewForm().
 		AddTextArea("Invoice / Payment request", "", 30).
 		AddButton("Send", nil).
@@ -18,6 +96,106 @@ func payPage(ui *UI) *Form {
 	return f
 }
 
+// keysendPage pays a node picked by alias without an invoice, with an
+// optional message. It returns the form and the view holding it with the
+// result.
+func keysendPage(ui *UI) (*tview.Form, tview.Primitive) {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Keysend ")
+	form.SetBorderColor(BorderColor)
+
+	result := tview.NewTextView()
+	result.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Result ")
+	result.SetDynamicColors(true)
+	result.SetWordWrap(true)
+
+	form.AddInputField("Destination alias (ID)", "", 70, nil, nil)
+	form.AddInputField("Amount (sats)", "", 20, tview.InputFieldInteger, nil)
+	form.AddInputField("Message", "", 70, nil, nil)
+
+	destination := form.GetFormItemByLabel("Destination alias (ID)").(*tview.InputField)
+	destination.SetAutocompleteFunc(func(currentText string) (entries []string) {
+		if len(currentText) < 2 {
+			return
+		}
+		for _, node := range listNodesByAliasOrID(ui, currentText) {
+			entries = append(entries, fmt.Sprintf("%s (%s)", node.alias, node.id))
+		}
+		return
+	})
+
+	sending := false
+	form.AddButton("Send", func() {
+		if sending {
+			ui.log.Warn("A keysend payment is still in flight\n")
+			return
+		}
+		nodeID := parseNodeID(destination.GetText())
+		if len(nodeID) != 66 {
+			ui.log.Warn("Pick a destination node\n")
+			return
+		}
+		sats, _ := strconv.ParseInt(form.GetFormItemByLabel("Amount (sats)").(*tview.InputField).GetText(), 10, 64)
+		if sats <= 0 {
+			ui.log.Warn("Enter an amount\n")
+			return
+		}
+		message := form.GetFormItemByLabel("Message").(*tview.InputField).GetText()
+		if message != "" && !getNodeVersion(ui).hasKeysendExtraTLVs() {
+			ui.log.Warn("Keysend messages need lightningd v22.11 or newer\n")
+			return
+		}
+
+		node := listNode(ui, nodeID)
+		amount := Sats(sats)
+		sending = true
+		result.SetText(fmt.Sprintf("[yellow]Sending %s %s to %s[white]\n", amount.Format(), amountUnit, tview.Escape(node.alias)))
+		keysend(ui, nodeID, amount, message, func(payment gjson.Result, err error) {
+			sending = false
+			if errors.Is(err, context.DeadlineExceeded) {
+				result.SetText(describeError(err) +
+					"\n\n[yellow]The payment may still go through, check the recent activity before sending it again[white]\n")
+				return
+			}
+			if err != nil {
+				result.SetText(describeError(err))
+				return
+			}
+			result.SetText("[green]Payment " + payment.Get("status").String() + "[white]\n")
+			keysendColumn(payment, node).Print(result)
+		})
+	})
+	form.AddButton("Cancel", func() {
+		ui.FocusMenu()
+	})
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(form, 11, 0, true)
+	layout.AddItem(result, 0, 1, false)
+	return form, layout
+}
+
+// keysendColumn lists the outcome of a keysend payment, with the fee of the
+// route taken.
+func keysendColumn(payment gjson.Result, node Node) *InfoColumn {
+	amount := msatField(payment, "amount_msat", "msatoshi")
+	sent := msatField(payment, "amount_sent_msat", "msatoshi_sent")
+	fee := sent - amount
+	var ppm int64
+	if amount > 0 {
+		ppm = int64(fee) * 1000000 / int64(amount)
+	}
+	c := NewInfoColumn("[white]", "[yellow]")
+	c.AddRow("Sent to", tview.Escape(node.alias))
+	c.AddRow("Amount ("+amountUnit+")", amount.Format())
+	// fees of small payments are often below a sat
+	c.AddRow("Route fee (msat)", fmt.Sprintf("%s (%d ppm)", formatSats(int64(fee)), ppm))
+	c.AddRow("Parts", fmt.Sprintf("%d", payment.Get("parts").Int()))
+	c.AddRow("Preimage", payment.Get("payment_preimage").String())
+	return c
+}
+
 func validatePay(bolt11 string, lastChar rune) bool {
 	_, err := decodepay.Decodepay(bolt11)
 	if err != nil {
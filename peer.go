@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math/big"
+)
+
+type Peer struct {
+	id        string
+	alias     string
+	connected bool
+	netaddr   []string
+	features  string
+	channels  int64
+}
+
+var featureNames = map[int]string{
+	0:  "data_loss_protect",
+	4:  "upfront_shutdown_script",
+	6:  "gossip_queries",
+	8:  "var_onion",
+	12: "static_remotekey",
+	14: "payment_secret",
+	16: "basic_mpp",
+	18: "large_channels",
+	20: "anchor_outputs",
+	22: "anchors_zero_fee_htlc",
+	26: "shutdown_anysegwit",
+	28: "dual_fund",
+	38: "onion_messages",
+	44: "channel_type",
+}
+
+// hasFeature checks if either the compulsory (even) or optional (odd) bit of
+// feature is set in the hex encoded features.
+func hasFeature(features string, feature int) bool {
+	bits, ok := new(big.Int).SetString(features, 16)
+	if !ok {
+		return false
+	}
+	even := feature - feature%2
+	return bits.Bit(even) == 1 || bits.Bit(even+1) == 1
+}
+
+// decodeFeatures returns the names of the known features set in the hex
+// encoded features.
+func decodeFeatures(features string) []string {
+	var names []string
+	for bit := 0; bit < 64; bit += 2 {
+		name, known := featureNames[bit]
+		if known && hasFeature(features, bit) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
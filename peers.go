@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// last measured round trip time per peer, read by the pages loading in the
+// background
+var peerLatency = make(map[string]time.Duration)
+var peerLatencyLock sync.Mutex
+
+// getPeerLatency returns the last round trip time measured to a peer.
+func getPeerLatency(id string) (time.Duration, bool) {
+	peerLatencyLock.Lock()
+	defer peerLatencyLock.Unlock()
+	rtt, exists := peerLatency[id]
+	return rtt, exists
+}
+
+func getPeers(ui *UI) ([]Peer, error) {
+	var peers []Peer
+
+	results, err := listPeers(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, data := range results.Get("peers").Array() {
+		id := data.Get("id").String()
+		alias := listNode(ui, id).alias
+		if alias == "" {
+			alias = id
+		}
+		var netaddr []string
+		for _, addr := range data.Get("netaddr").Array() {
+			netaddr = append(netaddr, addr.String())
+		}
+		ui.recordMetric("peer."+id+".connected", boolMetric(data.Get("connected").Bool()))
+		peers = append(peers, Peer{
+			id:        id,
+			alias:     alias,
+			connected: data.Get("connected").Bool(),
+			netaddr:   netaddr,
+			features:  data.Get("features").String(),
+			channels:  peerChannelCount(data),
+		})
+	}
+
+	sort.Slice(peers, func(i, j int) bool {
+		p1 := peers[i]
+		p2 := peers[j]
+		if p1.connected != p2.connected {
+			return p1.connected
+		}
+		return strings.ToLower(p1.alias) < strings.ToLower(p2.alias)
+	})
+	return peers, nil
+}
+
+// pingPeer pings a peer in the background, and calls done once the round
+// trip time is recorded.
+func pingPeer(ui *UI, peer Peer, done func()) {
+	start := time.Now()
+	ping(ui, peer.id, func(_ gjson.Result, err error) {
+		rtt := time.Since(start)
+		peerLatencyLock.Lock()
+		if err != nil {
+			delete(peerLatency, peer.id)
+		} else {
+			peerLatency[peer.id] = rtt
+		}
+		peerLatencyLock.Unlock()
+
+		if err != nil {
+			ui.log.Warn(fmt.Sprintf("Ping %s failed: %s\n", peer.alias, err))
+		} else {
+			ui.log.Info(fmt.Sprintf("Ping %s: ", peer.alias))
+			ui.log.Ok(fmt.Sprintf("%dms\n", rtt.Milliseconds()))
+		}
+		done()
+	})
+}
+
+func peersPage(ui *UI) tview.Primitive {
+	peers, err := getPeers(ui)
+	if err != nil {
+		return ui.NewErrorPage("peers", err)
+	}
+
+	t := NewTable()
+	t.SetTitle(" Peers ")
+	t.SetBorder(true)
+	t.SetBorderColor(BorderColor)
+	t.SetSelectable(true, false)
+
+	t.AddColumnHeader("\n[bold]alias", tview.AlignLeft)
+	t.AddColumnHeader("\nnode id", tview.AlignLeft)
+	t.AddColumnHeader("\nstatus", tview.AlignCenter)
+	t.AddColumnHeader("\nchannels", tview.AlignRight)
+	t.AddColumnHeader("ping\n(ms)", tview.AlignRight)
+	t.AddColumnHeader("\naddresses", tview.AlignLeft)
+	t.AddColumnHeader("\nfeatures", tview.AlignLeft)
+	t.Separator(11)
+	rowOffset := t.GetRowCount()
+	t.Select(rowOffset, 0)
+	t.SetFixed(rowOffset, 1)
+
+	t.SetDoneFunc(func(key tcell.Key) {
+		ui.pages.SwitchToPage("dash")
+		ui.FocusMenu()
+	})
+
+	t.SetExport(ui, "peers", func() Export {
+		return peersExport(peers)
+	})
+
+	// Do not allow to select the header
+	t.SetSelectionChangedFunc(func(row, column int) {
+		if row < rowOffset {
+			t.Select(row+1, column)
+		}
+	})
+
+	selectedPeer := func() (Peer, bool) {
+		currentRow, _ := t.GetSelection()
+		if currentRow < rowOffset || currentRow-rowOffset >= len(peers) {
+			return Peer{}, false
+		}
+		return peers[currentRow-rowOffset], true
+	}
+
+	reload := func() {
+		ui.ReloadPage("peers")
+	}
+
+	// Keyboard handler
+	t.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'c':
+			if ui.HasPage("connectPeer") {
+				ui.DeletePage("connectPeer")
+			}
+			ui.AddPage("connectPeer", ui.NewConnectPeerPage(), true, true)
+			ui.SetFocus("connectPeer")
+		case 'd':
+			peer, ok := selectedPeer()
+			if !ok {
+				break
+			}
+			if ui.HasPage("disconnectPeer") {
+				ui.DeletePage("disconnectPeer")
+			}
+			ui.AddPage("disconnectPeer", ui.NewDisconnectPeerPage(peer, "peers"), true, true)
+			ui.SetFocus("disconnectPeer")
+		case 'p':
+			peer, ok := selectedPeer()
+			if !ok {
+				break
+			}
+			pingPeer(ui, peer, reload)
+		case 'r':
+			reload()
+		case 'h':
+			help := []string{
+				"j/k   - Scroll down/up              ",
+				"G/g   - Scroll to bottom/top        ",
+				"c     - Connect to a peer           ",
+				"d     - Disconnect selected peer    ",
+				"p     - Ping selected peer          ",
+				"r     - Reload peers                ",
+				"E     - Export table to CSV/JSON    ",
+				"h     - Toggle help                 ",
+				"ESC   - Focus menu pane             ",
+			}
+			if ui.HasPage("help") {
+				ui.DeletePage("help")
+			} else {
+				ui.AddPage("help", ui.NewHelpPage(help), true, true)
+			}
+		}
+		return event
+	})
+
+	for row, peer := range peers {
+		var state string
+		var aliasColor string
+		if peer.connected {
+			state = "[green]connected"
+			aliasColor = "[greenyellow]"
+		} else {
+			state = "[grey]disconnected"
+			aliasColor = "[#9DB27C]"
+		}
+		var latency string
+		if rtt, exists := getPeerLatency(peer.id); exists {
+			latency = fmt.Sprintf("%d", rtt.Milliseconds())
+		}
+
+		currentRow := row + rowOffset
+		t.SetCell(currentRow, 0,
+			tview.NewTableCell(aliasColor+peer.alias))
+		t.SetCell(currentRow, 1,
+			tview.NewTableCell("[grey]"+peer.id))
+		t.SetCell(currentRow, 2,
+			tview.NewTableCell(state).SetAlign(tview.AlignCenter))
+		t.SetCell(currentRow, 3,
+			tview.NewTableCell(fmt.Sprintf("%d", peer.channels)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 4,
+			tview.NewTableCell("[deepskyblue]"+latency).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 5,
+			tview.NewTableCell("[white]"+strings.Join(peer.netaddr, ", ")))
+		t.SetCell(currentRow, 6,
+			tview.NewTableCell("[lightyellow]"+strings.Join(decodeFeatures(peer.features), " ")))
+	}
+
+	return t
+}
+
+func (ui *UI) NewConnectPeerPage() tview.Primitive {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Connect to peer ")
+	form.SetBorderColor(BorderColor)
+	form.AddInputField("Node (id@host:port or alias)", "", 110, nil, nil)
+
+	searchAlias := form.GetFormItemByLabel("Node (id@host:port or alias)").(*tview.InputField)
+	searchAlias.SetAutocompleteFunc(func(currentText string) (entries []string) {
+
+		if len(currentText) < 2 || strings.Contains(currentText, "@") {
+			return
+		}
+		for _, node := range listNodesByAliasOrID(ui, currentText) {
+			entries = append(entries, fmt.Sprintf("%s (%s)", node.alias, node.id))
+		}
+		return
+	})
+
+	form.AddButton("Connect", func() {
+		target := strings.TrimSpace(searchAlias.GetText())
+
+		// an alias picked from the autocomplete list
+		matches := aliasIDRe.FindStringSubmatch(target)
+		if len(matches) == 2 {
+			target = matches[1]
+		}
+		if target == "" {
+			ui.log.Warn("Please enter a node to connect to\n")
+			return
+		}
+
+		ui.log.Info("Connecting to " + target + "\n")
+		connect(ui, target, func(results gjson.Result, err error) {
+			if err != nil {
+				ui.log.Warn(fmt.Sprintf("Error when connecting: %s\n", err))
+				return
+			}
+			ui.log.Ok("Connected to " + results.Get("id").String() + "\n")
+			ui.DeletePage("connectPeer")
+			ui.ReloadPage("peers")
+		})
+	})
+	form.AddButton("Cancel", func() {
+		ui.pages.HidePage("connectPeer")
+		ui.SetFocus("peers")
+	})
+
+	return ui.Modal(form, 120, 8)
+}
+
+// NewDisconnectPeerPage asks before disconnecting peer, then reloads the
+// parent page.
+func (ui *UI) NewDisconnectPeerPage(peer Peer, parent string) tview.Primitive {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Disconnect from " + peer.alias + " ")
+	form.SetBorderColor(BorderColor)
+	form.AddInputField("Peer", peer.id, 66, func(text string, lastChar rune) bool { return false }, nil)
+	// lightningd refuses to disconnect peers with active channels unless forced
+	form.AddCheckbox("Force", false, nil)
+	form.AddButton("Disconnect", func() {
+		force := form.GetFormItemByLabel("Force").(*tview.Checkbox).IsChecked()
+		ui.pages.HidePage("disconnectPeer")
+		ui.SetFocus(parent)
+		if _, err := disconnect(ui, peer.id, force); err != nil {
+			ui.log.Warn(fmt.Sprintf("Error when disconnecting %s: %s\n", peer.alias, err))
+			return
+		}
+		ui.log.Ok(fmt.Sprintf("Disconnected from %s\n", peer.alias))
+		ui.ReloadPage(parent)
+	})
+	form.AddButton("Cancel", func() {
+		ui.pages.HidePage("disconnectPeer")
+		ui.SetFocus(parent)
+	})
+
+	return ui.Modal(form, 80, 9)
+}
@@ -0,0 +1,351 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type PendingChannel struct {
+	shortChannelID string
+	fundingTxid    string
+	remoteAlias    string
+	state          string
+	capacity       int64
+	localBalance   int64
+	confirmations  int64
+	spendableIn    int64
+	unresolved     int64
+	status         string
+}
+
+type ClosedChannel struct {
+	shortChannelID string
+	remoteAlias    string
+	closeCause     string
+	opener         string
+	closer         string
+	capacity       int64
+	finalBalance   int64
+	onChainFees    int64
+}
+
+var (
+	spendableInRe = regexp.MustCompile(`in (\d+) blocks`)
+	unresolvedRe  = regexp.MustCompile(`(\d+) outputs? unresolved`)
+)
+
+// parseOnchainStatus extracts from lightningd's onchaind status messages the
+// number of blocks until our delayed output can be spent (-1 if unknown) and
+// the number of outputs (HTLCs included) still waiting to be swept.
+func parseOnchainStatus(status []string) (int64, int64) {
+	spendableIn := int64(-1)
+	unresolved := int64(0)
+	for _, line := range status {
+		if matches := spendableInRe.FindStringSubmatch(line); len(matches) == 2 {
+			blocks, _ := strconv.ParseInt(matches[1], 10, 64)
+			if spendableIn < 0 || blocks < spendableIn {
+				spendableIn = blocks
+			}
+		}
+		if matches := unresolvedRe.FindStringSubmatch(line); len(matches) == 2 {
+			unresolved, _ = strconv.ParseInt(matches[1], 10, 64)
+		}
+	}
+	return spendableIn, unresolved
+}
+
+// getPendingChannels returns the channels that are being opened or closed.
+func getPendingChannels(ui *UI) ([]PendingChannel, error) {
+	var pending []PendingChannel
+
+	info, err := getInfo(ui)
+	if err != nil {
+		return nil, err
+	}
+	blockheight := info.Get("blockheight").Int()
+
+	// confirmation height of our wallet transactions, for unconfirmed fundings
+	transactions, err := getTransactions(ui)
+	if err != nil {
+		return nil, err
+	}
+	txHeight := make(map[string]int64)
+	for _, tx := range transactions.Get("transactions").Array() {
+		txHeight[tx.Get("hash").String()] = tx.Get("blockheight").Int()
+	}
+
+	channels, err := listPeerChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	for _, channel := range channels {
+		state := channel.Get("state").String()
+		if state == "CHANNELD_NORMAL" {
+			continue
+		}
+		remoteNodeID := channel.peerID
+		remoteAlias := listNode(ui, remoteNodeID).alias
+		if remoteAlias == "" {
+			remoteAlias = remoteNodeID
+		}
+
+		shortChannelID := channel.Get("short_channel_id").String()
+		fundingTxid := channel.Get("funding_txid").String()
+		confirmations := int64(0)
+		if parsedBlock := strings.Split(shortChannelID, "x"); len(parsedBlock) == 3 {
+			block, err := strconv.ParseInt(parsedBlock[0], 10, 64)
+			if err == nil {
+				confirmations = blockheight - block + 1
+			}
+		} else if height := txHeight[fundingTxid]; height > 0 {
+			confirmations = blockheight - height + 1
+		}
+
+		var status []string
+		for _, line := range channel.Get("status").Array() {
+			status = append(status, line.String())
+		}
+		spendableIn, unresolved := parseOnchainStatus(status)
+		var lastStatus string
+		if len(status) > 0 {
+			lastStatus = status[len(status)-1]
+		}
+
+		pending = append(pending, PendingChannel{
+			shortChannelID: shortChannelID,
+			fundingTxid:    fundingTxid,
+			remoteAlias:    remoteAlias,
+			state:          state,
+			capacity:       msatField(channel.Result, "total_msat", "msatoshi_total").Sat(),
+			localBalance:   msatField(channel.Result, "to_us_msat", "msatoshi_to_us").Sat(),
+			confirmations:  confirmations,
+			spendableIn:    spendableIn,
+			unresolved:     unresolved,
+			status:         lastStatus,
+		})
+	}
+	return pending, nil
+}
+
+// getClosedChannels returns the channels in lightningd's closed channel
+// history, with the on-chain fees recorded by the bookkeeper plugin. Versions
+// before v23.05 don't keep the history, the bookkeeper plugin may not run.
+func getClosedChannels(ui *UI) ([]ClosedChannel, error) {
+	var closed []ClosedChannel
+
+	events, err := listAccountEvents(ui)
+	if err != nil && !isUnknownCommand(err) {
+		return nil, err
+	}
+	fees := make(map[string]Msat)
+	for _, event := range events.Get("events").Array() {
+		if event.Get("tag").String() == "onchain_fee" {
+			account := event.Get("account").String()
+			fees[account] += msatValue(event, "credit") - msatValue(event, "debit")
+		}
+	}
+
+	closedChannels, err := listClosedChannels(ui)
+	if err != nil && !isUnknownCommand(err) {
+		return nil, err
+	}
+	for _, channel := range closedChannels.Get("closedchannels").Array() {
+		remoteNodeID := channel.Get("peer_id").String()
+		remoteAlias := listNode(ui, remoteNodeID).alias
+		if remoteAlias == "" {
+			remoteAlias = remoteNodeID
+		}
+		closed = append(closed, ClosedChannel{
+			shortChannelID: channel.Get("short_channel_id").String(),
+			remoteAlias:    remoteAlias,
+			closeCause:     channel.Get("close_cause").String(),
+			opener:         channel.Get("opener").String(),
+			closer:         channel.Get("closer").String(),
+			capacity:       msatValue(channel, "total").Sat(),
+			finalBalance:   msatValue(channel, "final_to_us").Sat(),
+			onChainFees:    fees[channel.Get("channel_id").String()].Sat(),
+		})
+	}
+	return closed, nil
+}
+
+func formatPendingState(state string) string {
+	switch state {
+	case "OPENINGD", "CHANNELD_AWAITING_LOCKIN", "DUALOPEND_OPEN_INIT", "DUALOPEND_AWAITING_LOCKIN":
+		return "[orange]opening"
+	case "CHANNELD_SHUTTING_DOWN", "CLOSINGD_SIGEXCHANGE", "CLOSINGD_COMPLETE":
+		return "[lightgrey]closing"
+	case "AWAITING_UNILATERAL":
+		return "[orange]awaiting unilateral"
+	case "FUNDING_SPEND_SEEN":
+		return "[lightgrey]close seen"
+	case "ONCHAIN":
+		return "[lightgrey]onchain"
+	case "CLOSED":
+		return "[grey]closed"
+	}
+	return "[grey]" + strings.ToLower(state)
+}
+
+func pendingChannelsPage(ui *UI) tview.Primitive {
+	config, err := getConfig(ui)
+	if err != nil {
+		return ui.NewErrorPage("pending", err)
+	}
+	fundingConfirms := config.Get("funding-confirms").Int()
+	pending, err := getPendingChannels(ui)
+	if err != nil {
+		return ui.NewErrorPage("pending", err)
+	}
+	closed, err := getClosedChannels(ui)
+	if err != nil {
+		return ui.NewErrorPage("pending", err)
+	}
+
+	pendingTable := NewTable()
+	pendingTable.SetTitle(" Pending channels ")
+	pendingTable.SetBorder(true)
+	pendingTable.SetBorderColor(BorderColor)
+	pendingTable.SetSelectable(true, false)
+
+	pendingTable.AddColumnHeader("\n[bold]state", tview.AlignCenter)
+	pendingTable.AddColumnHeader("\ncapacity\n(sats)", tview.AlignRight)
+	pendingTable.AddColumnHeader("\nour balance\n(sats)", tview.AlignRight)
+	pendingTable.AddColumnHeader("\nconfirmations", tview.AlignRight)
+	pendingTable.AddColumnHeader("spendable\nin\n(blocks)", tview.AlignRight)
+	pendingTable.AddColumnHeader("unresolved\noutputs", tview.AlignRight)
+	pendingTable.AddColumnHeader("\nalias", tview.AlignLeft)
+	pendingTable.AddColumnHeader("\nstatus", tview.AlignLeft)
+	pendingTable.Separator(11)
+	pendingOffset := pendingTable.GetRowCount()
+	pendingTable.SetFixed(pendingOffset, 0)
+	pendingTable.Select(pendingOffset, 0)
+
+	pendingTable.SetExport(ui, "pending", func() Export {
+		return pendingExport(pending)
+	})
+	for row, channel := range pending {
+		var confirmations string
+		if strings.HasPrefix(formatPendingState(channel.state), "[orange]opening") {
+			confirmations = fmt.Sprintf("%d/%d", channel.confirmations, fundingConfirms)
+		} else {
+			confirmations = fmt.Sprintf("%d", channel.confirmations)
+		}
+		var spendableIn string
+		if channel.spendableIn >= 0 {
+			spendableIn = "[yellow]" + formatSats(channel.spendableIn)
+		}
+		var unresolved string
+		if channel.unresolved > 0 {
+			unresolved = "[orange]" + formatSats(channel.unresolved)
+		}
+
+		currentRow := row + pendingOffset
+		pendingTable.SetCell(currentRow, 0,
+			tview.NewTableCell(formatPendingState(channel.state)).SetAlign(tview.AlignCenter))
+		pendingTable.SetCell(currentRow, 1,
+			tview.NewTableCell(formatSats(channel.capacity)).SetAlign(tview.AlignRight))
+		pendingTable.SetCell(currentRow, 2,
+			tview.NewTableCell("[green]"+formatSats(channel.localBalance)).SetAlign(tview.AlignRight))
+		pendingTable.SetCell(currentRow, 3,
+			tview.NewTableCell(confirmations).SetAlign(tview.AlignRight))
+		pendingTable.SetCell(currentRow, 4,
+			tview.NewTableCell(spendableIn).SetAlign(tview.AlignRight))
+		pendingTable.SetCell(currentRow, 5,
+			tview.NewTableCell(unresolved).SetAlign(tview.AlignRight))
+		pendingTable.SetCell(currentRow, 6,
+			tview.NewTableCell("[greenyellow]"+channel.remoteAlias))
+		pendingTable.SetCell(currentRow, 7,
+			tview.NewTableCell("[grey]"+channel.status))
+	}
+
+	closedTable := NewTable()
+	closedTable.SetTitle(" Closed channels ")
+	closedTable.SetBorder(true)
+	closedTable.SetBorderColor(BorderColor)
+	closedTable.SetSelectable(true, false)
+
+	closedTable.AddColumnHeader("\n[bold]close type", tview.AlignCenter)
+	closedTable.AddColumnHeader("\nopener", tview.AlignCenter)
+	closedTable.AddColumnHeader("\ncloser", tview.AlignCenter)
+	closedTable.AddColumnHeader("\ncapacity\n(sats)", tview.AlignRight)
+	closedTable.AddColumnHeader("final\nbalance\n(sats)", tview.AlignRight)
+	closedTable.AddColumnHeader("on-chain\nfees\n(sats)", tview.AlignRight)
+	closedTable.AddColumnHeader("\nshort channel id", tview.AlignLeft)
+	closedTable.AddColumnHeader("\nalias", tview.AlignLeft)
+	closedTable.Separator(11)
+	closedOffset := closedTable.GetRowCount()
+	closedTable.SetFixed(closedOffset, 0)
+	closedTable.Select(closedOffset, 0)
+
+	totalFees := int64(0)
+	closedTable.SetExport(ui, "closed", func() Export {
+		return closedExport(closed)
+	})
+	for row, channel := range closed {
+		closer := channel.closer
+		if closer == "" {
+			closer = "[grey]unknown"
+		}
+		currentRow := row + closedOffset
+		closedTable.SetCell(currentRow, 0,
+			tview.NewTableCell("[lightgrey]"+channel.closeCause).SetAlign(tview.AlignCenter))
+		closedTable.SetCell(currentRow, 1,
+			tview.NewTableCell(channel.opener).SetAlign(tview.AlignCenter))
+		closedTable.SetCell(currentRow, 2,
+			tview.NewTableCell(closer).SetAlign(tview.AlignCenter))
+		closedTable.SetCell(currentRow, 3,
+			tview.NewTableCell(formatSats(channel.capacity)).SetAlign(tview.AlignRight))
+		closedTable.SetCell(currentRow, 4,
+			tview.NewTableCell("[green]"+formatSats(channel.finalBalance)).SetAlign(tview.AlignRight))
+		closedTable.SetCell(currentRow, 5,
+			tview.NewTableCell("[red]"+formatSats(channel.onChainFees)).SetAlign(tview.AlignRight))
+		closedTable.SetCell(currentRow, 6,
+			tview.NewTableCell("[grey]"+channel.shortChannelID))
+		closedTable.SetCell(currentRow, 7,
+			tview.NewTableCell("[greenyellow]"+channel.remoteAlias))
+		totalFees += channel.onChainFees
+	}
+	closedTable.Separator(11)
+	closedTable.SetCell(closedTable.GetRowCount(), 5,
+		tview.NewTableCell("[red]"+formatSats(totalFees)).SetAlign(tview.AlignRight))
+
+	// Do not allow to select the header
+	pendingTable.SetSelectionChangedFunc(func(row, column int) {
+		if row < pendingOffset {
+			pendingTable.Select(row+1, column)
+		}
+	})
+	closedTable.SetSelectionChangedFunc(func(row, column int) {
+		if row < closedOffset {
+			closedTable.Select(row+1, column)
+		}
+	})
+
+	page := tview.NewFlex()
+	page.SetDirection(tview.FlexRow)
+	page.AddItem(pendingTable, 0, 1, true)
+	page.AddItem(closedTable, 0, 1, false)
+
+	// Tab switches between the pending and the closed channels
+	pendingTable.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyTab {
+			ui.app.SetFocus(closedTable)
+			return
+		}
+		ui.FocusMenu()
+	})
+	closedTable.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyTab {
+			ui.app.SetFocus(pendingTable)
+			return
+		}
+		ui.FocusMenu()
+	})
+
+	return page
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type PricePoint struct {
+	time  time.Time
+	price float64
+}
+
+// PriceHistory is a price provider reading the price of a bitcoin over time
+// from a local file.
+type PriceHistory struct {
+	currency string
+	points   []PricePoint
+}
+
+func parsePriceTime(s string) (time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02"} {
+		if t, err := time.Parse(layout, s); err == nil {
+			return t, nil
+		}
+	}
+	if ts, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(ts, 0), nil
+	}
+	return time.Time{}, fmt.Errorf("can't parse date %q", s)
+}
+
+// LoadPriceHistory reads a CSV file of date,price lines. Dates can be
+// YYYY-MM-DD, RFC 3339 or unix timestamps. A header line is skipped.
+func LoadPriceHistory(path, currency string) (*PriceHistory, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	h := &PriceHistory{currency: currency}
+	r := csv.NewReader(f)
+	r.FieldsPerRecord = -1
+	for line := 1; ; line++ {
+		record, err := r.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(record) < 2 {
+			return nil, fmt.Errorf("%s:%d: expected date,price", path, line)
+		}
+		t, errTime := parsePriceTime(strings.TrimSpace(record[0]))
+		price, errPrice := strconv.ParseFloat(strings.TrimSpace(record[1]), 64)
+		if errTime != nil || errPrice != nil {
+			if line == 1 {
+				continue
+			}
+			return nil, fmt.Errorf("%s:%d: expected date,price", path, line)
+		}
+		h.points = append(h.points, PricePoint{t, price})
+	}
+	sort.Slice(h.points, func(i, j int) bool { return h.points[i].time.Before(h.points[j].time) })
+	return h, nil
+}
+
+// PriceAt returns the last price known at t, or the first one for earlier
+// times.
+func (h *PriceHistory) PriceAt(t time.Time) (float64, bool) {
+	if h == nil || len(h.points) == 0 {
+		return 0, false
+	}
+	idx := sort.Search(len(h.points), func(i int) bool { return h.points[i].time.After(t) })
+	if idx == 0 {
+		return h.points[0].price, true
+	}
+	return h.points[idx-1].price, true
+}
+
+func (h *PriceHistory) Price(t time.Time) (float64, error) {
+	price, ok := h.PriceAt(t)
+	if !ok {
+		return 0, errors.New("no prices")
+	}
+	return price, nil
+}
+
+func (h *PriceHistory) Currency() string {
+	return h.currency
+}
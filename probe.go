@@ -0,0 +1,344 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/rivo/tview"
+	"strconv"
+	"strings"
+)
+
+// how long a probe waits for its HTLC to fail back, in seconds
+const probeTimeout = 60
+
+// amounts probed by default, in sats
+const defaultProbeAmounts = "1000,10000,100000,1000000"
+
+// failure of the destination to the random payment hash of probes
+const wireUnknownPaymentDetails = "WIRE_INCORRECT_OR_UNKNOWN_PAYMENT_DETAILS"
+
+// failure of a hop without enough liquidity to forward
+const wireTemporaryChannelFailure = "WIRE_TEMPORARY_CHANNEL_FAILURE"
+
+// ProbeHop is a channel of the route of a probe, and the node it leads to.
+type ProbeHop struct {
+	ShortChannelID string
+	Direction      int
+	NodeID         string
+	Amount         Msat
+	Delay          int64
+}
+
+// ProbeResult is how sending amount to a node went. The failing hop is the
+// index in the route of the node which reported the failure, 0 being us.
+type ProbeResult struct {
+	Amount     Msat
+	Reachable  bool
+	Route      []ProbeHop
+	Fees       Msat
+	FailingHop int
+	Failure    string
+}
+
+// probe sends amount to destination along the route getroute finds, with a
+// payment hash nobody knows the preimage of, so the payment can only fail.
+// Failing at the destination means the funds got there. What is learnt of
+// the liquidity of remote channels is recorded for the next routes.
+func probe(ui *UI, destination string, amount Msat) (ProbeResult, error) {
+	result := ProbeResult{Amount: amount, FailingHop: -1}
+	estimates := getLiquidityEstimates(ui)
+
+	params := map[string]interface{}{
+		"id":                           destination,
+		getNodeVersion(ui).amountKey(): int64(amount),
+		"riskfactor":                   10,
+	}
+	if excluded := estimates.Exclusions(amount); len(excluded) > 0 {
+		params["exclude"] = excluded
+	}
+	routes, err := call(ui, "getroute", params)
+	var rpcError *RPCError
+	if errors.As(err, &rpcError) {
+		result.Failure = "no route: " + rpcError.Message
+		return result, nil
+	}
+	if err != nil {
+		return result, err
+	}
+	route := routes.Get("route")
+	for _, hop := range route.Array() {
+		result.Route = append(result.Route, ProbeHop{
+			ShortChannelID: hop.Get("channel").String(),
+			Direction:      int(hop.Get("direction").Int()),
+			NodeID:         hop.Get("id").String(),
+			Amount:         msatField(hop, "amount_msat", "msatoshi"),
+			Delay:          hop.Get("delay").Int(),
+		})
+	}
+	if len(result.Route) == 0 {
+		result.Failure = "no route"
+		return result, nil
+	}
+	result.Fees = result.Route[0].Amount - result.Route[len(result.Route)-1].Amount
+
+	hash, err := randomPaymentHash()
+	if err != nil {
+		return result, err
+	}
+	_, err = call(ui, "sendpay", map[string]interface{}{
+		"route":        json.RawMessage(route.Raw),
+		"payment_hash": hash,
+	})
+	if err == nil {
+		_, err = call(ui, "waitsendpay", map[string]interface{}{
+			"payment_hash": hash,
+			"timeout":      probeTimeout,
+		})
+	}
+	if err == nil {
+		// someone knew the preimage after all
+		result.Reachable = true
+		result.FailingHop = len(result.Route)
+	} else if errors.As(err, &rpcError) {
+		data := rpcError.DataResult()
+		result.Failure = data.Get("failcodename").String()
+		if result.Failure == "" {
+			result.Failure = rpcError.Message
+		}
+		if erring := data.Get("erring_index"); erring.Exists() {
+			result.FailingHop = int(erring.Int())
+		}
+		result.Reachable = result.Failure == wireUnknownPaymentDetails && result.FailingHop == len(result.Route)
+	} else {
+		return result, err
+	}
+
+	// without an erring index there's no telling how far the probe went
+	if result.FailingHop < 0 {
+		return result, nil
+	}
+	// the first channel is ours, listfunds tells its balance
+	for i := 1; i < len(result.Route) && i < result.FailingHop; i++ {
+		hop := result.Route[i]
+		estimates.Forwarded(ui, hop.ShortChannelID, hop.Direction, hop.Amount)
+	}
+	if result.Failure == wireTemporaryChannelFailure && result.FailingHop > 0 && result.FailingHop < len(result.Route) {
+		hop := result.Route[result.FailingHop]
+		estimates.Failed(ui, hop.ShortChannelID, hop.Direction, hop.Amount)
+	}
+	return result, nil
+}
+
+func randomPaymentHash() (string, error) {
+	hash := make([]byte, 32)
+	if _, err := rand.Read(hash); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash), nil
+}
+
+// searchMaxSendable narrows down the largest amount which reaches the
+// destination, knowing reachable (or 0) does and unreachable doesn't, with
+// at most steps probes. It stops once the range is within 1% and returns
+// the bounds found.
+func searchMaxSendable(reachable, unreachable Msat, steps int, reaches func(Msat) bool) (Msat, Msat) {
+	for i := 0; i < steps && unreachable-reachable > unreachable/100; i++ {
+		amount := Sats((reachable + (unreachable-reachable)/2).Sat())
+		if amount <= reachable {
+			break
+		}
+		if reaches(amount) {
+			reachable = amount
+		} else {
+			unreachable = amount
+		}
+	}
+	return reachable, unreachable
+}
+
+// parseProbeAmounts reads amounts in sats separated by commas.
+func parseProbeAmounts(text string) ([]Msat, error) {
+	var amounts []Msat
+	for _, item := range strings.Split(text, ",") {
+		item = strings.ReplaceAll(strings.TrimSpace(item), "_", "")
+		if item == "" {
+			continue
+		}
+		sats, err := strconv.ParseInt(item, 10, 64)
+		if err != nil || sats <= 0 {
+			return nil, fmt.Errorf("can't parse amount %q", item)
+		}
+		amounts = append(amounts, Sats(sats))
+	}
+	if len(amounts) == 0 {
+		return nil, errors.New("no amounts to probe")
+	}
+	return amounts, nil
+}
+
+// describeProbe formats the outcome of a probe for the results pane.
+func describeProbe(ui *UI, result ProbeResult) string {
+	amount := result.Amount.Format() + " " + amountUnit
+	var text string
+	if result.Reachable {
+		text = fmt.Sprintf("[green]reachable[white]   %s, fees %s %s over %d hops\n",
+			amount, result.Fees.Format(), amountUnit, len(result.Route))
+	} else {
+		text = fmt.Sprintf("[red]unreachable[white] %s", amount)
+		if result.FailingHop >= 0 && result.FailingHop < len(result.Route) {
+			hop := result.Route[result.FailingHop]
+			text += fmt.Sprintf(", failed at hop %d %s on %s", result.FailingHop,
+				tview.Escape(probeNodeName(ui, hopNodeID(ui, result, result.FailingHop))), hop.ShortChannelID)
+		}
+		text += ": " + tview.Escape(result.Failure) + "\n"
+	}
+	for i, hop := range result.Route {
+		text += fmt.Sprintf("  [grey]%d[white] %-15s %s [grey]%s %s[white]\n", i+1, hop.ShortChannelID,
+			tview.Escape(probeNodeName(ui, hop.NodeID)), hop.Amount.Format(), amountUnit)
+	}
+	return text
+}
+
+// hopNodeID returns the id of the node at index i of the route, 0 being us.
+func hopNodeID(ui *UI, result ProbeResult, i int) string {
+	if i == 0 {
+		info, err := getInfo(ui)
+		if err != nil {
+			return "us"
+		}
+		return info.Get("id").String()
+	}
+	return result.Route[i-1].NodeID
+}
+
+func probeNodeName(ui *UI, id string) string {
+	if node := listNode(ui, id); node.alias != "" {
+		return node.alias
+	}
+	if len(id) > 16 {
+		return id[:16] + "…"
+	}
+	return id
+}
+
+// probePage probes a node at several amounts and, optionally, searches for
+// the largest amount which gets there.
+func probePage(ui *UI) tview.Primitive {
+	form := tview.NewForm()
+	form.SetBorder(true)
+	form.SetTitle(" Probe ")
+	form.SetBorderColor(BorderColor)
+
+	results := tview.NewTextView()
+	results.SetBorder(true).SetBorderColor(BorderColor).SetTitle(" Results ")
+	results.SetDynamicColors(true)
+	results.SetScrollable(true)
+	results.SetText("Probes send payments nobody can claim, which fail back without moving funds.\n" +
+		"Failing at the destination means the amount can get there.\n")
+
+	form.AddInputField("Destination alias (ID)", "", 70, nil, nil)
+	form.AddInputField("Amounts (sats)", defaultProbeAmounts, 40, nil, nil)
+	form.AddCheckbox("Find max sendable", true, nil)
+	form.AddInputField("Max probes", "8", 5, tview.InputFieldInteger, nil)
+
+	destination := form.GetFormItemByLabel("Destination alias (ID)").(*tview.InputField)
+	destination.SetAutocompleteFunc(func(currentText string) (entries []string) {
+		if len(currentText) < 2 {
+			return
+		}
+		for _, node := range listNodesByAliasOrID(ui, currentText) {
+			entries = append(entries, fmt.Sprintf("%s (%s)", node.alias, node.id))
+		}
+		return
+	})
+
+	probing := false
+	// aliases are looked up on the UI's goroutine, which owns the node cache
+	write := func(text func() string) {
+		ui.app.QueueUpdateDraw(func() {
+			fmt.Fprint(results, text())
+			results.ScrollToEnd()
+		})
+	}
+
+	form.AddButton("Probe", func() {
+		if probing {
+			ui.log.Warn("Probes still running\n")
+			return
+		}
+		nodeID := parseNodeID(destination.GetText())
+		if len(nodeID) != 66 {
+			ui.log.Warn("Pick a destination node\n")
+			return
+		}
+		amounts, err := parseProbeAmounts(form.GetFormItemByLabel("Amounts (sats)").(*tview.InputField).GetText())
+		if err != nil {
+			ui.log.Warn(err.Error() + "\n")
+			return
+		}
+		findMax := form.GetFormItemByLabel("Find max sendable").(*tview.Checkbox).IsChecked()
+		maxProbes, _ := strconv.Atoi(form.GetFormItemByLabel("Max probes").(*tview.InputField).GetText())
+
+		probing = true
+		results.SetText(fmt.Sprintf("[yellow]Probing %s[white]\n\n", tview.Escape(probeNodeName(ui, nodeID))))
+		go func() {
+			defer ui.app.QueueUpdateDraw(func() { probing = false })
+
+			reaches := func(amount Msat) bool {
+				result, err := probe(ui, nodeID, amount)
+				if err != nil {
+					write(func() string { return "[red]" + tview.Escape(err.Error()) + "[white]\n" })
+					return false
+				}
+				write(func() string { return describeProbe(ui, result) + "\n" })
+				return result.Reachable
+			}
+			var reachable Msat
+			var failed []Msat
+			for _, amount := range amounts {
+				if reaches(amount) {
+					if amount > reachable {
+						reachable = amount
+					}
+				} else {
+					failed = append(failed, amount)
+				}
+			}
+			if !findMax {
+				return
+			}
+			// liquidity may have moved between probes, smaller amounts
+			// failing after bigger ones went through
+			var unreachable Msat
+			for _, amount := range failed {
+				if amount > reachable && (unreachable == 0 || amount < unreachable) {
+					unreachable = amount
+				}
+			}
+			if unreachable == 0 {
+				write(func() string {
+					return fmt.Sprintf("[yellow]Max sendable[white] at least %s %s\n", reachable.Format(), amountUnit)
+				})
+				return
+			}
+			low, high := searchMaxSendable(reachable, unreachable, maxProbes, reaches)
+			write(func() string {
+				return fmt.Sprintf("[yellow]Max sendable[white] between %s and %s %s\n", low.Format(), high.Format(), amountUnit)
+			})
+		}()
+	})
+	form.AddButton("Cancel", func() {
+		ui.FocusMenu()
+	})
+	form.SetCancelFunc(func() {
+		ui.FocusMenu()
+	})
+
+	layout := tview.NewFlex().SetDirection(tview.FlexRow)
+	layout.AddItem(form, 13, 0, true)
+	layout.AddItem(results, 0, 1, false)
+	return layout
+}
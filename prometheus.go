@@ -0,0 +1,189 @@
+package main
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// PrometheusExporter serves the figures of the overview and of the channels
+// table in the Prometheus text format. Scrapes within cacheFor of the last
+// one get the same figures, so several Prometheus servers or a short scrape
+// interval don't keep lightningd busy.
+type PrometheusExporter struct {
+	ui       *UI
+	token    string
+	cacheFor time.Duration
+
+	lock     sync.Mutex
+	cached   []byte
+	cachedAt time.Time
+}
+
+// ServeMetrics serves /metrics on listen until it fails.
+func (ui *UI) ServeMetrics(listen, token string, cacheFor time.Duration) error {
+	if token == "" && !loopbackAddress(listen) {
+		return fmt.Errorf("serving on %s needs a --token", listen)
+	}
+	exporter := &PrometheusExporter{ui: ui, token: token, cacheFor: cacheFor}
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", exporter)
+
+	ui.log.Info("Serving metrics on http://" + listen + "/metrics\n")
+	return newHTTPServer(listen, mux).ListenAndServe()
+}
+
+func (p *PrometheusExporter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	auth := []byte(r.Header.Get("Authorization"))
+	if p.token != "" && subtle.ConstantTimeCompare(auth, []byte("Bearer "+p.token)) != 1 {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.cached == nil || time.Since(p.cachedAt) >= p.cacheFor {
+		p.cached = p.scrape()
+		p.cachedAt = time.Now()
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w.Write(p.cached)
+}
+
+// scrape collects the metrics. When lightningd can't be reached, it only
+// reports cluster_up 0, so alerts can be set on it.
+func (p *PrometheusExporter) scrape() []byte {
+	start := time.Now()
+	m := &promWriter{}
+
+	summary, err := getNodeSummary(p.ui)
+	var channels []Channel
+	if err == nil {
+		channels, err = getChannels(p.ui)
+	}
+	m.metric("cluster_up", "gauge", "Whether lightningd replied to the last scrape.", nil, boolMetric(err == nil))
+	if err != nil {
+		p.ui.log.Warn("Scrape failed: " + err.Error() + "\n")
+		return m.Bytes()
+	}
+
+	m.metric("cluster_node_blockheight", "gauge", "Block height seen by the node.", nil, float64(summary.Blockheight))
+	m.metric("cluster_node_peers", "gauge", "Number of peers.", nil, float64(summary.Peers))
+	m.header("cluster_node_channels", "gauge", "Number of channels by state.")
+	m.sample("cluster_node_channels", promLabels{"state", "active"}, float64(summary.ActiveChannels))
+	m.sample("cluster_node_channels", promLabels{"state", "inactive"}, float64(summary.InactiveChannels))
+	m.sample("cluster_node_channels", promLabels{"state", "pending"}, float64(summary.PendingChannels))
+	m.metric("cluster_node_onchain_sat", "gauge", "Confirmed on-chain funds.", nil, float64(summary.OnChainFunds))
+	m.metric("cluster_node_utxos", "gauge", "Number of confirmed UTXOs.", nil, float64(summary.UTXOs))
+	m.metric("cluster_node_outbound_sat", "gauge", "Funds on our side of the channels.", nil, float64(summary.Outbound))
+	m.metric("cluster_node_inbound_sat", "gauge", "Funds on the remote side of the channels.", nil, float64(summary.Inbound))
+	m.metric("cluster_node_fees_collected_sat", "counter", "Routing fees collected.", nil, float64(summary.FeesCollected))
+	m.metric("cluster_node_fees_spent_onchain_sat", "counter", "Fees paid for on-chain transactions.", nil, float64(summary.FeesSpent))
+	m.metric("cluster_node_profit_loss_sat", "gauge", "Routing fees collected less on-chain fees spent.", nil, float64(summary.ProfitLoss))
+	m.header("cluster_feerate_sat_per_vbyte", "gauge", "On-chain fee rates estimated by the node.")
+	for _, feerate := range feerateNames {
+		m.sample("cluster_feerate_sat_per_vbyte", promLabels{"type", feerate.name}, summary.Feerates[feerate.name].SatPerVByte())
+	}
+
+	type channelMetric struct {
+		name, kind, help string
+		value            func(c Channel) (float64, bool)
+	}
+	channelMetrics := []channelMetric{
+		{"cluster_channel_active", "gauge", "Whether the channel is in normal operation.",
+			func(c Channel) (float64, bool) { return boolMetric(c.active), true }},
+		{"cluster_channel_capacity_sat", "gauge", "Capacity of the channel.",
+			func(c Channel) (float64, bool) { return c.capacity.SatFloat(), true }},
+		{"cluster_channel_local_balance_sat", "gauge", "Funds on our side of the channel.",
+			func(c Channel) (float64, bool) { return c.localBalance.SatFloat(), true }},
+		{"cluster_channel_remote_balance_sat", "gauge", "Funds on the remote side of the channel.",
+			func(c Channel) (float64, bool) { return c.remoteBalance.SatFloat(), true }},
+		{"cluster_channel_local_base_fee_msat", "gauge", "Base fee we charge.",
+			func(c Channel) (float64, bool) { return float64(c.localBaseFee), true }},
+		{"cluster_channel_local_fee_rate_ppm", "gauge", "Fee rate we charge.",
+			func(c Channel) (float64, bool) { return float64(c.localFeeRate), true }},
+		{"cluster_channel_remote_base_fee_msat", "gauge", "Base fee the peer charges.",
+			func(c Channel) (float64, bool) { return float64(c.remoteBaseFee), true }},
+		{"cluster_channel_remote_fee_rate_ppm", "gauge", "Fee rate the peer charges.",
+			func(c Channel) (float64, bool) { return float64(c.remoteFeeRate), true }},
+		{"cluster_channel_local_fees_earned_sat", "counter", "Fees we earned forwarding out through the channel.",
+			func(c Channel) (float64, bool) { return c.localFees.SatFloat(), true }},
+		{"cluster_channel_remote_fees_earned_sat", "counter", "Fees the peer earned forwarding in through the channel.",
+			func(c Channel) (float64, bool) { return c.remoteFees.SatFloat(), true }},
+		{"cluster_channel_last_forward_age_seconds", "gauge", "Time since the last forward through the channel.",
+			func(c Channel) (float64, bool) {
+				return time.Since(time.Unix(int64(c.lastForward), 0)).Seconds(), c.lastForward > 0
+			}},
+		{"cluster_channel_reliability_percent", "gauge", "Share of the forwards through the channel which succeeded.",
+			func(c Channel) (float64, bool) { return c.reliability.score, c.reliability.score >= 0 }},
+	}
+	for _, metric := range channelMetrics {
+		m.header(metric.name, metric.kind, metric.help)
+		for _, c := range channels {
+			if value, ok := metric.value(c); ok {
+				labels := promLabels{"short_channel_id", c.shortChannelID, "peer_id", c.remoteNodeID, "alias", c.remoteAlias}
+				m.sample(metric.name, labels, value)
+			}
+		}
+	}
+
+	peers := make(map[string]Channel)
+	for _, c := range channels {
+		peers[c.remoteNodeID] = c
+	}
+	var peerIDs []string
+	for id := range peers {
+		peerIDs = append(peerIDs, id)
+	}
+	sort.Strings(peerIDs)
+	m.header("cluster_peer_connected", "gauge", "Whether the peer is connected.")
+	for _, id := range peerIDs {
+		m.sample("cluster_peer_connected", promLabels{"peer_id", id, "alias", peers[id].remoteAlias}, boolMetric(peers[id].peerConnected))
+	}
+
+	m.metric("cluster_scrape_duration_seconds", "gauge", "How long collecting the metrics took.", nil, time.Since(start).Seconds())
+	return m.Bytes()
+}
+
+// promLabels are label names and values, in pairs.
+type promLabels []string
+
+// promWriter writes metrics in the Prometheus text format.
+type promWriter struct {
+	bytes.Buffer
+}
+
+func (w *promWriter) header(name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func (w *promWriter) sample(name string, labels promLabels, value float64) {
+	w.WriteString(name)
+	if len(labels) > 0 {
+		var pairs []string
+		for i := 0; i+1 < len(labels); i += 2 {
+			pairs = append(pairs, labels[i]+"="+promQuote(labels[i+1]))
+		}
+		w.WriteString("{" + strings.Join(pairs, ",") + "}")
+	}
+	w.WriteString(" " + strconv.FormatFloat(value, 'f', -1, 64) + "\n")
+}
+
+func (w *promWriter) metric(name, kind, help string, labels promLabels, value float64) {
+	w.header(name, kind, help)
+	w.sample(name, labels, value)
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func promQuote(value string) string {
+	return `"` + promEscaper.Replace(value) + `"`
+}
@@ -77,7 +77,12 @@ func (ui *UI) handleCreateInvoice(form *tview.Form, qr *tview.TextView) {
 
 	switch selectedType {
 	case "onchain":
-		newAddr := getNewAddr(ui).Get("bech32").String()
+		addr, err := getNewAddr(ui)
+		if err != nil {
+			ui.log.Warn("Error creating an address: " + err.Error() + "\n")
+			return
+		}
+		newAddr := addr.Get("bech32").String()
 		qrs, err := QRCode(newAddr)
 		if err != nil {
 			ui.log.Warn("Error generating newaddr QR code: " + err.Error() + "\n")
@@ -85,11 +90,15 @@ func (ui *UI) handleCreateInvoice(form *tview.Form, qr *tview.TextView) {
 		qr.SetText("\n" + qrs)
 
 	case "bolt11":
-		inv := getInvoice(ui, map[string]interface{}{
-			"msatoshi":    sats * 1000,
+		inv, err := getInvoice(ui, map[string]interface{}{
+			getNodeVersion(ui).amountKey(): sats * 1000,
 			"label":       generateLabel(),
 			"description": descField.GetText(),
 			"expiry":      timeoutField.GetText() + "d"})
+		if err != nil {
+			ui.log.Warn("Error creating the invoice: " + err.Error() + "\n")
+			return
+		}
 
 		bolt11 := inv.Get("bolt11").String()
 		paymentHash := inv.Get("payment_hash").String()
@@ -115,7 +124,11 @@ func (ui *UI) handleCreateInvoice(form *tview.Form, qr *tview.TextView) {
 	case "bolt12":
 		ui.log.Info("Bolt12 selected\n")
 
-		o := offer(ui, sats, descField.GetText())
+		o, err := offer(ui, sats, descField.GetText())
+		if err != nil {
+			ui.log.Warn("Error creating the offer: " + err.Error() + "\n")
+			return
+		}
 
 		bolt12 := o.Get("bolt12").String()
 		offerID := o.Get("offer_id").String()
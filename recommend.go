@@ -0,0 +1,307 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"math"
+	"sort"
+	"strings"
+)
+
+const (
+	// channels younger than this are never recommended for closing
+	minCloseAgeDays = 30
+	// days without forwards after which a channel is considered dead
+	idleCloseDays = 60
+	// days without forwards after which fees are considered too high
+	idleFeeDays = 14
+	// local balance share under/over which a channel is unbalanced
+	skewThreshold = 0.1
+	// blocks per day
+	blocksPerDay = 144
+)
+
+type Recommendation struct {
+	channel    Channel
+	health     float64
+	idleDays   float64
+	ageDays    float64
+	yield      int64
+	balance    float64
+	inboundFee int64
+	action     string
+	feeRate    int64
+	reasons    []string
+}
+
+// recommend scores a channel from 0 to 100 and suggests what to do with it.
+// inboundFee is the median fee rate other nodes charge to reach the peer.
+func recommend(channel Channel, inboundFee int64) Recommendation {
+	r := Recommendation{
+		channel:    channel,
+		ageDays:    float64(channel.age) / blocksPerDay,
+		inboundFee: inboundFee,
+		action:     "keep",
+		feeRate:    channel.localFeeRate,
+	}
+	if channel.lastForward > 0 {
+		r.idleDays = formatDaysSince(channel.lastForward)
+	} else {
+		r.idleDays = r.ageDays
+	}
+	if channel.capacity > 0 {
+		r.balance = float64(channel.localBalance) / float64(channel.capacity)
+	}
+	// yearly fees earned per sat of capacity
+	if channel.capacity > 0 && r.ageDays > 0 {
+		r.yield = int64(float64(channel.localFees) / float64(channel.capacity) * 1000000 * 365 / math.Max(r.ageDays, 1))
+	}
+	uptime := channel.reliability.uptime7d
+
+	r.health = 100
+	r.health -= math.Min(r.idleDays/idleCloseDays, 1) * 30
+	r.health -= math.Abs(0.5-r.balance) * 2 * 20
+	if r.yield < 100 {
+		r.health -= float64(100-r.yield) / 100 * 20
+	}
+	if uptime >= 0 {
+		r.health -= (1 - uptime) * 20
+	}
+	if inboundFee > 0 && channel.localFeeRate > 2*inboundFee {
+		r.health -= 10
+	}
+	r.health = math.Max(r.health, 0)
+
+	switch {
+	case r.ageDays >= minCloseAgeDays && r.idleDays >= idleCloseDays && uptime >= 0 && uptime < 0.9:
+		r.action = "close"
+		r.reasons = append(r.reasons, fmt.Sprintf("no forward for %.0f days", r.idleDays),
+			fmt.Sprintf("peer online %.0f%% of the last 7 days", uptime*100))
+		if !channel.peerConnected {
+			r.reasons = append(r.reasons, "peer offline, may need a unilateral close")
+		}
+	case r.ageDays >= minCloseAgeDays && r.idleDays >= idleCloseDays && channel.peerConnected:
+		r.action = "coop close"
+		r.reasons = append(r.reasons, fmt.Sprintf("no forward for %.0f days", r.idleDays),
+			fmt.Sprintf("earns %d ppm/year of capacity", r.yield))
+	case r.balance < 0.2 && r.idleDays < 7:
+		r.action = "raise fee"
+		r.feeRate = int64(math.Max(float64(channel.localFeeRate)*1.25, float64(inboundFee)))
+		r.reasons = append(r.reasons, fmt.Sprintf("only %.0f%% outbound left and still forwarding", r.balance*100))
+	case inboundFee > 0 && channel.localFeeRate < inboundFee/2 && r.idleDays < idleFeeDays:
+		r.action = "raise fee"
+		r.feeRate = inboundFee
+		r.reasons = append(r.reasons, fmt.Sprintf("our %d ppm is far below the %d ppm others charge", channel.localFeeRate, inboundFee))
+	case r.idleDays >= idleFeeDays && r.balance > 0.6 && inboundFee > 0 && channel.localFeeRate > inboundFee:
+		r.action = "lower fee"
+		r.feeRate = inboundFee
+		r.reasons = append(r.reasons, fmt.Sprintf("idle for %.0f days", r.idleDays),
+			fmt.Sprintf("our %d ppm is above the %d ppm others charge", channel.localFeeRate, inboundFee))
+	case r.idleDays >= 2*idleFeeDays && r.balance > 0.6 && channel.localFeeRate > 0:
+		r.action = "lower fee"
+		r.feeRate = channel.localFeeRate * 3 / 4
+		r.reasons = append(r.reasons, fmt.Sprintf("idle for %.0f days with %.0f%% outbound", r.idleDays, r.balance*100))
+	case (r.balance < skewThreshold || r.balance > 1-skewThreshold) && channel.localFees > 0:
+		r.action = "rebalance"
+		r.reasons = append(r.reasons, fmt.Sprintf("%.0f%% of the balance on our side", r.balance*100),
+			fmt.Sprintf("earned %s %s so far", channel.localFees.Format(), amountUnit))
+	}
+	return r
+}
+
+func getRecommendations(ui *UI) ([]Recommendation, error) {
+	g, err := getGraph(ui)
+	if err != nil {
+		return nil, err
+	}
+	channels, err := getChannels(ui)
+	if err != nil {
+		return nil, err
+	}
+	var recommendations []Recommendation
+	for _, channel := range channels {
+		if channel.state != "CHANNELD_NORMAL" {
+			continue
+		}
+		var inboundFee int64
+		if gn, exists := g.nodes[channel.remoteNodeID]; exists {
+			inboundFee = gn.medianInboundFee
+		}
+		recommendations = append(recommendations, recommend(channel, inboundFee))
+	}
+	// worst channels first
+	sort.SliceStable(recommendations, func(i, j int) bool {
+		return recommendations[i].health < recommendations[j].health
+	})
+	return recommendations, nil
+}
+
+func formatAction(action string) string {
+	switch action {
+	case "close":
+		return "[red]close"
+	case "coop close":
+		return "[orange]coop close"
+	case "rebalance":
+		return "[deepskyblue]rebalance"
+	case "raise fee", "lower fee":
+		return "[yellow]" + action
+	}
+	return "[green]" + action
+}
+
+func recommendationsPage(ui *UI) tview.Primitive {
+	recommendations, err := getRecommendations(ui)
+	if err != nil {
+		return ui.NewErrorPage("recommendations", err)
+	}
+
+	t := NewTable()
+	t.SetTitle(" Recommendations ")
+	t.SetBorder(true)
+	t.SetBorderColor(BorderColor)
+	t.SetSelectable(true, false)
+
+	t.AddColumnHeader("\n[bold]health", tview.AlignRight)
+	t.AddColumnHeader("\nsuggestion", tview.AlignCenter)
+	t.AddColumnHeader("suggested\nfee_rate\n(ppm)", tview.AlignRight)
+	t.AddColumnHeader("\nidle\n(days)", tview.AlignRight)
+	t.AddColumnHeader("\nyield\n(ppm/year)", tview.AlignRight)
+	t.AddColumnHeader("\noutbound\n(%)", tview.AlignRight)
+	t.AddColumnHeader("\nuptime\n7d (%)", tview.AlignRight)
+	t.AddColumnHeader("local\nfee_rate\n(ppm)", tview.AlignRight)
+	t.AddColumnHeader("others\nfee_rate\n(ppm)", tview.AlignRight)
+	t.AddColumnHeader("\nage\n(days)", tview.AlignRight)
+	t.AddColumnHeader("\nalias", tview.AlignLeft)
+	t.AddColumnHeader("\nreasoning", tview.AlignLeft)
+	t.Separator(11)
+	rowOffset := t.GetRowCount()
+	t.Select(rowOffset, 0)
+	t.SetFixed(rowOffset, 2)
+
+	t.SetDoneFunc(func(key tcell.Key) {
+		ui.pages.SwitchToPage("dash")
+		ui.FocusMenu()
+	})
+
+	// Do not allow to select the header
+	t.SetSelectionChangedFunc(func(row, column int) {
+		if row < rowOffset {
+			t.Select(row+1, column)
+		}
+	})
+
+	t.SetExport(ui, "recommendations", func() Export {
+		return recommendationsExport(recommendations)
+	})
+
+	selected := func() (Recommendation, bool) {
+		currentRow, _ := t.GetSelection()
+		if currentRow < rowOffset || currentRow-rowOffset >= len(recommendations) {
+			return Recommendation{}, false
+		}
+		return recommendations[currentRow-rowOffset], true
+	}
+
+	t.SetSelectedFunc(func(row, column int) {
+		r, ok := selected()
+		if !ok {
+			return
+		}
+		if ui.HasPage("channelDetail") {
+			ui.DeletePage("channelDetail")
+		}
+		ui.AddPage("channelDetail", ui.NewChannelDetailPage(r.channel, "recommendations"), true, true)
+		ui.SetFocus("channelDetail")
+	})
+
+	// Keyboard handler
+	t.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Rune() {
+		case 'f':
+			r, ok := selected()
+			if !ok {
+				break
+			}
+			if ui.HasPage("channelFees") {
+				ui.DeletePage("channelFees")
+			}
+			// prefill the form with the suggested fee rate
+			channel := r.channel
+			channel.localFeeRate = r.feeRate
+			ui.AddPage("channelFees", ui.NewChannelFeesPage(channel, "recommendations"), true, true)
+			ui.SetFocus("channelFees")
+		case 'c':
+			r, ok := selected()
+			if !ok {
+				break
+			}
+			if ui.HasPage("closeChannel") {
+				ui.DeletePage("closeChannel")
+			}
+			ui.AddPage("closeChannel", ui.NewCloseChannelPage(r.channel, "recommendations"), true, true)
+			ui.SetFocus("closeChannel")
+		case 'h':
+			help := []string{
+				"j/k   - Scroll down/up              ",
+				"G/g   - Scroll to bottom/top        ",
+				"Enter - Go to channel details page  ",
+				"f     - Set suggested channel fees  ",
+				"c     - Close selected channel      ",
+				"E     - Export table to CSV/JSON    ",
+				"h     - Toggle help                 ",
+				"ESC   - Focus menu pane             ",
+			}
+			if ui.HasPage("help") {
+				ui.DeletePage("help")
+			} else {
+				ui.AddPage("help", ui.NewHelpPage(help), true, true)
+			}
+		}
+		return event
+	})
+
+	for row, r := range recommendations {
+		var feeRate string
+		if r.feeRate != r.channel.localFeeRate {
+			feeRate = "[yellow]" + formatSats(r.feeRate)
+		}
+		var inboundFee string
+		if r.inboundFee > 0 {
+			inboundFee = "[lightyellow]" + formatSats(r.inboundFee)
+		}
+		idle := fmt.Sprintf("%.1f", r.idleDays)
+		if r.idleDays > idleCloseDays {
+			idle = "[red]" + idle
+		}
+
+		currentRow := row + rowOffset
+		t.SetCell(currentRow, 0,
+			tview.NewTableCell(formatReliability(r.health)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 1,
+			tview.NewTableCell(formatAction(r.action)).SetAlign(tview.AlignCenter))
+		t.SetCell(currentRow, 2,
+			tview.NewTableCell(feeRate).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 3,
+			tview.NewTableCell(idle).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 4,
+			tview.NewTableCell(formatSats(r.yield)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 5,
+			tview.NewTableCell(fmt.Sprintf("%.0f", r.balance*100)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 6,
+			tview.NewTableCell(formatReliability(100*r.channel.reliability.uptime7d)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 7,
+			tview.NewTableCell("[deepskyblue]"+formatSats(r.channel.localFeeRate)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 8,
+			tview.NewTableCell(inboundFee).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 9,
+			tview.NewTableCell(fmt.Sprintf("%.0f", r.ageDays)).SetAlign(tview.AlignRight))
+		t.SetCell(currentRow, 10,
+			tview.NewTableCell("[greenyellow]"+r.channel.remoteAlias))
+		t.SetCell(currentRow, 11,
+			tview.NewTableCell("[white]"+strings.Join(r.reasons, ", ")))
+	}
+
+	return t
+}
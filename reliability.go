@@ -0,0 +1,185 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+type Reliability struct {
+	uptime24h      float64
+	uptime7d       float64
+	uptime30d      float64
+	active7d       float64
+	forwardSuccess float64
+	score          float64
+}
+
+// samplerInterval is how often connectivity is sampled, 0 when it isn't.
+var samplerInterval time.Duration
+
+// uptime returns the share of the time since that series was 1, each point
+// counting until the next one, or -1 when there are no points. Gaps much
+// longer than the sampling interval, while cluster wasn't running, don't
+// count.
+func (m *MetricStore) uptime(series string, since time.Time) float64 {
+	points := m.Points(series, since)
+	if len(points) == 0 {
+		return -1
+	}
+	maxGap := m.resolution
+	if samplerInterval > maxGap {
+		maxGap = samplerInterval
+	}
+	maxGap *= 2
+	now := time.Now().Unix()
+	total := 0.0
+	weight := 0.0
+	for i, point := range points {
+		end := now
+		if i+1 < len(points) {
+			end = points[i+1].Time
+		}
+		span := math.Min(float64(end-point.Time), maxGap.Seconds())
+		if span <= 0 {
+			// a single recent point still counts
+			span = 1
+		}
+		total += point.Value * span
+		weight += span
+	}
+	return total / weight
+}
+
+// getForwardOutcomes counts per outgoing channel the settled and failed
+// forwards.
+func getForwardOutcomes(ui *UI) (map[string]int64, map[string]int64, error) {
+	settled := make(map[string]int64)
+	failed := make(map[string]int64)
+	forwards, err := getForwards(ui, map[string]interface{}{})
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, forward := range forwards.Get("forwards").Array() {
+		outChan := forward.Get("out_channel").String()
+		switch forward.Get("status").String() {
+		case "settled":
+			settled[outChan] += 1
+		case "failed", "local_failed":
+			failed[outChan] += 1
+		}
+	}
+	return settled, failed, nil
+}
+
+// computeReliability scores a channel from 0 to 100 from the uptime of the
+// peer, how often the channel was active in gossip and the share of
+// forwards it succeeded to relay. Unknown values don't count.
+func computeReliability(ui *UI, shortChannelID, peerID string, settled, failed int64) Reliability {
+	now := time.Now()
+	peer := "peer." + peerID + ".connected"
+	r := Reliability{
+		uptime24h:      ui.metrics.uptime(peer, now.Add(-24*time.Hour)),
+		uptime7d:       ui.metrics.uptime(peer, now.Add(-7*24*time.Hour)),
+		uptime30d:      ui.metrics.uptime(peer, now.Add(-30*24*time.Hour)),
+		active7d:       ui.metrics.uptime("channel."+shortChannelID+".active", now.Add(-7*24*time.Hour)),
+		forwardSuccess: -1,
+	}
+	if settled+failed > 0 {
+		r.forwardSuccess = float64(settled) / float64(settled+failed)
+	}
+
+	weights := []struct {
+		value  float64
+		weight float64
+	}{
+		{r.uptime24h, 1},
+		{r.uptime7d, 2},
+		{r.uptime30d, 1},
+		{r.active7d, 2},
+		{r.forwardSuccess, 2},
+	}
+	total := 0.0
+	weight := 0.0
+	for _, w := range weights {
+		if w.value >= 0 {
+			total += w.value * w.weight
+			weight += w.weight
+		}
+	}
+	if weight > 0 {
+		r.score = 100 * total / weight
+	} else {
+		r.score = -1
+	}
+	return r
+}
+
+// formatReliability colours a percentage, -1 meaning unknown.
+func formatReliability(value float64) string {
+	switch {
+	case value < 0:
+		return "[grey]n/a"
+	case value < 80:
+		return fmt.Sprintf("[red]%.1f", value)
+	case value < 95:
+		return fmt.Sprintf("[orange]%.1f", value)
+	default:
+		return fmt.Sprintf("[green]%.1f", value)
+	}
+}
+
+// sampleConnectivity records whether our peers are connected and our
+// channels active in gossip.
+func sampleConnectivity(ui *UI) {
+	info, err := callSilently(ui, "getinfo")
+	if err != nil {
+		return
+	}
+	localID := info.Get("id").String()
+
+	results, err := callSilently(ui, peerChannelsMethod(getNodeVersion(ui)))
+	if err != nil {
+		return
+	}
+	ourChannels := peerChannels(results)
+	connected := make(map[string]bool)
+	for _, channel := range ourChannels {
+		connected[channel.peerID] = channel.peerConnected
+	}
+	for id, c := range connected {
+		ui.recordMetric("peer."+id+".connected", boolMetric(c))
+	}
+
+	// our side of our channels, listchannels only lists the sides whose
+	// source is the one given
+	channels, err := callSilently(ui, "listchannels", map[string]interface{}{"source": localID})
+	if err != nil {
+		return
+	}
+	active := make(map[string]bool)
+	for _, channel := range channels.Get("channels").Array() {
+		active[channel.Get("short_channel_id").String()] = channel.Get("active").Bool()
+	}
+	for _, channel := range ourChannels {
+		shortChannelID := channel.Get("short_channel_id").String()
+		if shortChannelID == "" || channel.Get("state").String() != "CHANNELD_NORMAL" {
+			continue
+		}
+		ui.recordMetric("channel."+shortChannelID+".active", boolMetric(active[shortChannelID]))
+	}
+}
+
+// StartSampler samples connectivity every interval in the background.
+func (ui *UI) StartSampler(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	samplerInterval = interval
+	go func() {
+		for {
+			sampleConnectivity(ui)
+			time.Sleep(interval)
+		}
+	}()
+}
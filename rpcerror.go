@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/fiatjaf/lightningd-gjson-rpc"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"github.com/tidwall/gjson"
+	"net"
+	"time"
+)
+
+// RPCMethodNotFound is the JSON-RPC code of calls to unknown methods.
+const RPCMethodNotFound = -32601
+
+// RPCError is an error lightningd replied to a call with.
+type RPCError struct {
+	Method  string
+	Code    int
+	Message string
+	Data    interface{}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s failed: %s (%d)", e.Method, e.Message, e.Code)
+}
+
+// DataResult returns the data of the error, such as the failing hop of a
+// payment, for reading with gjson.
+func (e *RPCError) DataResult() gjson.Result {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return gjson.Result{}
+	}
+	return gjson.ParseBytes(data)
+}
+
+// ConnectionError means lightningd can't be reached on its socket.
+type ConnectionError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConnectionError) Error() string {
+	return fmt.Sprintf("can't connect to lightningd on %s: %s", e.Path, e.Err)
+}
+
+func (e *ConnectionError) Unwrap() error {
+	return e.Err
+}
+
+// checkSocket tells if lightningd accepts connections on path. The RPC client
+// retries to connect for most of a minute, this fails right away, for the
+// reconnect loop to poll.
+func checkSocket(path string) error {
+	conn, err := net.DialTimeout("unix", path, time.Second)
+	if err != nil {
+		return &ConnectionError{path, err}
+	}
+	conn.Close()
+	return nil
+}
+
+// rpcError converts the errors of the RPC client to ours.
+func rpcError(ui *UI, method string, err error) error {
+	switch e := err.(type) {
+	case lightning.ErrorCommand:
+		return &RPCError{method, e.Code, e.Message, e.Data}
+	case lightning.ErrorConnect:
+		return &ConnectionError{ui.rpcPath, errors.New(e.Message)}
+	case lightning.ErrorConnectionBroken:
+		return &ConnectionError{ui.rpcPath, e}
+	case lightning.ErrorTimeout:
+		return fmt.Errorf("%s timed out: %w", method, context.DeadlineExceeded)
+	}
+	switch err {
+	case context.DeadlineExceeded:
+		return fmt.Errorf("%s timed out: %w", method, err)
+	case context.Canceled:
+		return fmt.Errorf("%s was cancelled: %w", method, err)
+	}
+	return err
+}
+
+func isConnectionError(err error) bool {
+	var connectionError *ConnectionError
+	return errors.As(err, &connectionError)
+}
+
+// isUnknownCommand tells if err is lightningd not knowing the method, such as
+// those of plugins that aren't running or of newer versions.
+func isUnknownCommand(err error) bool {
+	var rpcError *RPCError
+	return errors.As(err, &rpcError) && rpcError.Code == RPCMethodNotFound
+}
+
+// describeError explains err to the user, with lightningd's data if any.
+func describeError(err error) string {
+	var rpcError *RPCError
+	if errors.As(err, &rpcError) {
+		text := fmt.Sprintf("[red]lightningd replied to %s with error %d[white]\n\n%s",
+			rpcError.Method, rpcError.Code, tview.Escape(rpcError.Message))
+		if rpcError.Data != nil {
+			data, _ := json.MarshalIndent(rpcError.Data, "", "  ")
+			text += "\n\n" + tview.Escape(string(data))
+		}
+		return text
+	}
+	if isConnectionError(err) {
+		return "[red]Connection to lightningd lost[white]\n\n" + tview.Escape(err.Error()) +
+			"\n\nThe page reloads once lightningd is back."
+	}
+	return "[red]Error[white]\n\n" + tview.Escape(err.Error())
+}
+
+// NewErrorPage shows why the page name couldn't be loaded, r loads it again.
+func (ui *UI) NewErrorPage(name string, err error) tview.Primitive {
+	tv := tview.NewTextView()
+	tv.SetBorder(true)
+	tv.SetBorderColor(BorderColor)
+	tv.SetTitle(" Error ")
+	tv.SetDynamicColors(true)
+	tv.SetWordWrap(true)
+	tv.SetTextAlign(tview.AlignCenter)
+	tv.SetText("\n\n" + describeError(err) + "\n\n\n(r) Retry    (ESC) Back to the menu")
+
+	tv.SetDoneFunc(func(key tcell.Key) {
+		ui.FocusMenu()
+	})
+	tv.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Rune() == 'r' {
+			ui.ReloadPage(name)
+			return nil
+		}
+		return event
+	})
+	return tv
+}
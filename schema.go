@@ -0,0 +1,180 @@
+package main
+
+import (
+	"github.com/tidwall/gjson"
+	"regexp"
+	"strconv"
+	"sync"
+)
+
+// NodeVersion is the version of lightningd we are connected to. Core
+// Lightning renamed and removed RPC methods and fields over time, the version
+// tells which ones the node understands.
+type NodeVersion struct {
+	raw                 string
+	major, minor, patch int
+	known               bool
+}
+
+var versionPattern = regexp.MustCompile(`^v?(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// parseNodeVersion parses versions such as v0.10.2, v0.10.2-modded, v23.05.2
+// or v23.08rc1.
+func parseNodeVersion(s string) NodeVersion {
+	v := NodeVersion{raw: s}
+	m := versionPattern.FindStringSubmatch(s)
+	if m == nil {
+		return v
+	}
+	v.major, _ = strconv.Atoi(m[1])
+	v.minor, _ = strconv.Atoi(m[2])
+	v.patch, _ = strconv.Atoi(m[3])
+	v.known = true
+	return v
+}
+
+// AtLeast tells if v is major.minor or newer. Versions we can't parse are
+// development builds, assumed to be newer than any release.
+func (v NodeVersion) AtLeast(major, minor int) bool {
+	if !v.known {
+		return true
+	}
+	if v.major != major {
+		return v.major > major
+	}
+	return v.minor >= minor
+}
+
+func (v NodeVersion) String() string {
+	return v.raw
+}
+
+// hasListPeerChannels tells if channels are listed by listpeerchannels
+// rather than under each peer of listpeers (v23.02).
+func (v NodeVersion) hasListPeerChannels() bool {
+	return v.AtLeast(23, 2)
+}
+
+// hasSetChannel tells if fees are set with setchannel, which replaced
+// setchannelfee (v0.11).
+func (v NodeVersion) hasSetChannel() bool {
+	return v.AtLeast(0, 11)
+}
+
+// amountKey is the name of the amount parameter of invoice, getroute and
+// keysend, renamed from msatoshi in v0.12.
+func (v NodeVersion) amountKey() string {
+	if v.AtLeast(0, 12) {
+		return "amount_msat"
+	}
+	return "msatoshi"
+}
+
+// hasKeysendExtraTLVs tells if keysend takes extratlvs, which carry the
+// message of keysend payments (v22.11).
+func (v NodeVersion) hasKeysendExtraTLVs() bool {
+	return v.AtLeast(22, 11)
+}
+
+var nodeVersion *NodeVersion
+var nodeVersionLock sync.Mutex
+
+// getNodeVersion returns the version of the node, asking for it the first
+// time. It is used from the background sampler too, so it doesn't log calls.
+func getNodeVersion(ui *UI) NodeVersion {
+	nodeVersionLock.Lock()
+	defer nodeVersionLock.Unlock()
+
+	if nodeVersion != nil {
+		return *nodeVersion
+	}
+	info, err := callSilently(ui, "getinfo")
+	if err != nil {
+		return NodeVersion{}
+	}
+	v := parseNodeVersion(info.Get("version").String())
+	nodeVersion = &v
+	ui.log.Info("lightningd " + v.String() + "\n")
+	return v
+}
+
+// PeerChannel is one of our channels, with the peer it is with.
+type PeerChannel struct {
+	gjson.Result
+	peerID        string
+	peerConnected bool
+}
+
+// peerChannelsMethod is the method listing our channels.
+func peerChannelsMethod(v NodeVersion) string {
+	if v.hasListPeerChannels() {
+		return "listpeerchannels"
+	}
+	return "listpeers"
+}
+
+// peerChannels flattens the result of listpeerchannels, or of listpeers on
+// older versions, to a list of channels.
+func peerChannels(results gjson.Result) []PeerChannel {
+	var channels []PeerChannel
+	if results.Get("channels").Exists() {
+		for _, channel := range results.Get("channels").Array() {
+			channels = append(channels, PeerChannel{
+				channel,
+				channel.Get("peer_id").String(),
+				channel.Get("peer_connected").Bool(),
+			})
+		}
+		return channels
+	}
+	for _, peer := range results.Get("peers").Array() {
+		for _, channel := range peer.Get("channels").Array() {
+			channels = append(channels, PeerChannel{
+				channel,
+				peer.Get("id").String(),
+				peer.Get("connected").Bool(),
+			})
+		}
+	}
+	return channels
+}
+
+func listPeerChannels(ui *UI) ([]PeerChannel, error) {
+
+	results, err := call(ui, peerChannelsMethod(getNodeVersion(ui)))
+	return peerChannels(results), err
+
+}
+
+// peerChannelCount is the number of channels we have with a listpeers peer.
+func peerChannelCount(peer gjson.Result) int64 {
+	if n := peer.Get("num_channels"); n.Exists() {
+		return n.Int()
+	}
+	return peer.Get("channels.#").Int()
+}
+
+// channelLocalFee reads the fees we charge from our own view of the channel,
+// which unlike gossip is there before the channel is announced.
+func channelLocalFee(channel gjson.Result) (Fee, bool) {
+	for _, prefix := range []string{"updates.local.", ""} {
+		base := channel.Get(prefix + "fee_base_msat")
+		if !base.Exists() {
+			continue
+		}
+		m, err := MsatFromResult(base)
+		if err != nil {
+			continue
+		}
+		return Fee{int64(m), channel.Get(prefix + "fee_proportional_millionths").Int()}, true
+	}
+	return Fee{}, false
+}
+
+// gossipFee reads the fees of one side of a listchannels channel.
+func gossipFee(side gjson.Result) Fee {
+	return Fee{
+		int64(msatField(side, "base_fee_millisatoshi")),
+		side.Get("fee_per_millionth").Int(),
+	}
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sync"
+)
+
+// Settings are the user preferences kept between runs.
+type Settings struct {
+	ChannelColumns []string  `json:"channel_columns,omitempty"`
+	ChannelSort    []SortKey `json:"channel_sort,omitempty"`
+	ShowFiat       bool      `json:"show_fiat,omitempty"`
+}
+
+var settings *Settings
+var settingsLock sync.Mutex
+
+func getSettings(ui *UI) *Settings {
+	settingsLock.Lock()
+	defer settingsLock.Unlock()
+	if settings != nil {
+		return settings
+	}
+	settings = &Settings{}
+	data, err := ioutil.ReadFile(ui.dataPath("settings.json"))
+	if err != nil {
+		if !os.IsNotExist(err) {
+			ui.log.Warn("Can't read settings: " + err.Error() + "\n")
+		}
+		return settings
+	}
+	if err := json.Unmarshal(data, settings); err != nil {
+		ui.log.Warn("Can't read settings: " + err.Error() + "\n")
+	}
+	return settings
+}
+
+func saveSettings(ui *UI) {
+	data, err := json.MarshalIndent(getSettings(ui), "", "  ")
+	if err == nil {
+		err = ioutil.WriteFile(ui.dataPath("settings.json"), data, 0600)
+	}
+	if err != nil {
+		ui.log.Warn("Can't save settings: " + err.Error() + "\n")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// dataPath returns the path of the file name in the data directory, creating
+// the directory if needed.
+func (ui *UI) dataPath(name string) string {
+	if err := os.MkdirAll(ui.dataDir, 0700); err != nil {
+		ui.log.Warn("Can't create data directory " + ui.dataDir + ": " + err.Error() + "\n")
+	}
+	return filepath.Join(ui.dataDir, name)
+}
+
+// appendJSONLines appends every record as a JSON document on its own line.
+func appendJSONLines(path string, records ...interface{}) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	encoder := json.NewEncoder(f)
+	for _, record := range records {
+		if err := encoder.Encode(record); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// readJSONLines calls each with every line of a file written by
+// appendJSONLines. A missing file is not an error.
+func readJSONLines(path string, each func(line []byte) error) error {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		if err := each(scanner.Bytes()); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
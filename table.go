@@ -8,11 +8,14 @@ import (
 
 type Table struct {
 	tview.Table
+	ui     *UI
+	name   string
+	export func() Export
 }
 
 func NewTable() *Table {
 	tt := tview.NewTable()
-	return &Table{*tt}
+	return &Table{Table: *tt}
 }
 
 func (t *Table) AddColumnHeader(name string, align int) {
@@ -32,3 +35,26 @@ func (t *Table) Separator(length int) {
 			tview.NewTableCell(strings.Repeat("─", length)))
 	}
 }
+
+// SetExport makes the rows returned by export writable to a file with the
+// 'E' key. name is used for the default file name.
+func (t *Table) SetExport(ui *UI, name string, export func() Export) {
+	t.ui = ui
+	t.name = name
+	t.export = export
+}
+
+func (t *Table) InputHandler() func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+	handler := t.Table.InputHandler()
+	return func(event *tcell.EventKey, setFocus func(p tview.Primitive)) {
+		if event.Rune() == 'E' && t.export != nil {
+			if t.ui.HasPage("export") {
+				t.ui.DeletePage("export")
+			}
+			t.ui.AddPage("export", t.ui.NewExportPage(t.name, t.export(), t), true, true)
+			t.ui.SetFocus("export")
+			return
+		}
+		handler(event, setFocus)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"github.com/gdamore/tcell/v2"
 	"github.com/rivo/tview"
 	"strings"
+	"sync"
 )
 
 const (
@@ -20,25 +21,31 @@ type UI struct {
 	menu       *tview.List
 	log        *Log
 	rpcPath    string
+	dataDir    string
+	metrics    *MetricStore
 }
 
+const topBarText = " Cluster v0.1 - Press h for help"
+
+// topBar doubles as a banner while lightningd can't be reached.
+var topBar *tview.TextView
+
 func NewTopBar() tview.Primitive {
-	topbar := tview.NewTextView()
-	topbar.SetBorder(false)
-	topbar.SetText(" Cluster v0.1 - Press h for help")
-	topbar.SetTextColor(TopbarTextColor)
-	topbar.SetBackgroundColor(MainColor)
-	return topbar
+	topBar = tview.NewTextView()
+	topBar.SetBorder(false)
+	topBar.SetText(topBarText)
+	topBar.SetTextColor(TopbarTextColor)
+	topBar.SetBackgroundColor(MainColor)
+	return topBar
 }
 
 func NewMenu(ui *UI) *tview.List {
 	menu := tview.NewList().ShowSecondaryText(false).
 		AddItem("Node info", "Display general information about this node", 'i', func() {
-				ui.AddPage("dash", dashPage(ui), true, true)
-				ui.pages.SwitchToPage("dash")
-				ui.SetFocus("dash")
+			ui.ReloadPage("dash")
 		}).
-		AddItem("Pay", "Pay an invoice", 'p', func() {
+		AddItem("Pay", "Pay an invoice or a node", 'p', func() {
+			ui.AddPage("pay", payPage(ui), true, true)
 			ui.pages.SwitchToPage("pay")
 			ui.SetFocus("pay")
 		}).
@@ -48,14 +55,34 @@ func NewMenu(ui *UI) *tview.List {
 			ui.SetFocus("receive")
 		}).
 		AddItem("Channels", "Display a list of all channels", 'c', func() {
-			ui.AddPage("channels", channelsPage(ui), true, true)
-			ui.pages.SwitchToPage("channels")
-			ui.SetFocus("channels")
+			ui.ReloadPage("channels")
+		}).
+		AddItem("Recommendations", "Suggestions to keep channels healthy", 'a', func() {
+			ui.ReloadPage("recommendations")
+		}).
+		AddItem("Pending / closed channels", "Display opening, closing and closed channels", 'o', func() {
+			ui.ReloadPage("pending")
+		}).
+		AddItem("Peers", "Display a list of all peers", 'e', func() {
+			ui.ReloadPage("peers")
+		}).
+		AddItem("Network", "Explore the network graph", 'n', func() {
+			ui.ReloadPage("network")
 		}).
 		AddItem("Dual-funding / Liquidity Ads", "Dual-fund or find liquidity", 'l', func() {
-			ui.AddPage("dualfunding", dualFundingPage(ui), true, true)
-			ui.pages.SwitchToPage("dualfunding")
-			ui.SetFocus("dualfunding")
+			ui.ReloadPage("dualfunding")
+		}).
+		AddItem("Probe", "Probe the routes and liquidity to a node", 'b', func() {
+			ui.AddPage("probe", probePage(ui), true, true)
+			ui.pages.SwitchToPage("probe")
+			ui.SetFocus("probe")
+		}).
+		AddItem("Fiat amounts", "Show or hide amounts in fiat", 'f', func() {
+			ui.ToggleFiat()
+		}).
+		AddItem("Calls in flight", "Calls waiting for lightningd", 'w', func() {
+			ui.AddPage("inflight", ui.NewInFlightPage(), true, true)
+			ui.SetFocus("inflight")
 		}).
 		AddItem("Help", "", 'h', func() {
 			if ui.HasPage("help") {
@@ -63,9 +90,18 @@ func NewMenu(ui *UI) *tview.List {
 			} else {
 				help := []string{
 					"(i)   - Show high level overview of the node",
-					"(p)   - Pay an invoice                      ",
+					"(p)   - Pay an invoice, or a node (keysend) ",
 					"(r)   - Receive sats (create an invoice)    ",
 					"(c)   - Show channels                       ",
+					"(a)   - Show channel recommendations        ",
+					"(o)   - Show pending and closed channels    ",
+					"(e)   - Show peers                          ",
+					"(n)   - Explore the network graph           ",
+					"(b)   - Probe routes and liquidity to a node",
+					"(f)   - Show or hide fiat amounts           ",
+					"(w)   - Show calls in flight, stop waiting  ",
+					"E     - Export the focused table            ",
+					"L     - Export the ledger (overview page)   ",
 					"(h)   - Toggle help                         ",
 					"(ESC) - Go back to the menu                 ",
 					"(q)   - Quit the application (menu only)    "}
@@ -128,6 +164,93 @@ func (ui *UI) DeletePage(name string) bool {
 	delete(ui.primitives, name)
 	return true
 }
+// pageBuilder returns what builds the page name when it shows data from
+// lightningd, nil otherwise.
+func pageBuilder(name string) func(ui *UI) tview.Primitive {
+	switch name {
+	case "dash":
+		return dashPage
+	case "channels":
+		return channelsPage
+	case "recommendations":
+		return recommendationsPage
+	case "network":
+		return networkPage
+	case "peers":
+		return peersPage
+	case "pending":
+		return pendingChannelsPage
+	case "dualfunding":
+		return dualFundingPage
+	}
+	return nil
+}
+
+// pageLoadLock keeps pages from loading at the same time, they share the
+// caches of client.go.
+var pageLoadLock sync.Mutex
+
+// ReloadPage rebuilds a page with fresh data and shows it. The page loads in
+// the background while a placeholder stands in, so the UI stays usable, and
+// the calls in flight can be looked at, while lightningd is slow to reply.
+func (ui *UI) ReloadPage(name string) {
+	build := pageBuilder(name)
+	if build == nil {
+		ui.SetFocus(name)
+		return
+	}
+	ui.loadPage(name, build, false)
+}
+
+// LoadModal shows the page name over the current one, such as a form
+// needing data from lightningd, loading it in the background like
+// ReloadPage.
+func (ui *UI) LoadModal(name string, build func(ui *UI) tview.Primitive) {
+	ui.loadPage(name, build, true)
+}
+
+func (ui *UI) loadPage(name string, build func(ui *UI) tview.Primitive, modal bool) {
+	loading := tview.NewTextView()
+	loading.SetBorder(true)
+	loading.SetBorderColor(BorderColor)
+	loading.SetTextAlign(tview.AlignCenter)
+	loading.SetText("\n\nLoading...\n\n\n(ESC) Back to the menu")
+	loading.SetDoneFunc(func(key tcell.Key) {
+		if modal {
+			ui.DeletePage(name)
+		}
+		ui.FocusMenu()
+	})
+	page := tview.NewFlex()
+	if modal {
+		page.AddItem(ui.Modal(loading, 40, 9), 0, 1, true)
+	} else {
+		page.AddItem(loading, 0, 1, true)
+	}
+	ui.AddPage(name, page, true, true)
+	if !modal {
+		ui.pages.SwitchToPage(name)
+	}
+	ui.SetFocus(name)
+
+	go func() {
+		pageLoadLock.Lock()
+		p := build(ui)
+		pageLoadLock.Unlock()
+		ui.app.QueueUpdateDraw(func() {
+			// loaded again meanwhile
+			if ui.primitives[name] != page {
+				return
+			}
+			focused := ui.app.GetFocus() == loading
+			page.Clear()
+			page.AddItem(p, 0, 1, true)
+			if focused {
+				ui.app.SetFocus(page)
+			}
+		})
+	}()
+}
 func (ui *UI) SetFocus(name string) tview.Primitive {
 	p := ui.primitives[name]
 	ui.app.SetFocus(p)
@@ -163,7 +286,9 @@ func (ui *UI) NewLayout() tview.Primitive {
 func (ui *UI) Run() {
 	layout := ui.NewLayout()
 	ui.SetupPages()
-	if err := ui.app.SetRoot(layout, true).SetFocus(ui.menu).Run(); err != nil {
+	overlay = tview.NewPages()
+	overlay.AddPage("layout", layout, true, true)
+	if err := ui.app.SetRoot(overlay, true).SetFocus(ui.menu).Run(); err != nil {
 		panic(err)
 	}
 }
@@ -0,0 +1,352 @@
+package main
+
+import (
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"github.com/rivo/tview"
+	"html"
+	"html/template"
+	"io/fs"
+	"net"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// web holds the templates and stylesheet of the web dashboard, so it works
+// offline.
+//
+//go:embed web
+var webAssets embed.FS
+
+const tokenCookie = "cluster_token"
+
+// how long clients may take to send the headers of a request, so idle ones
+// don't hold connections open, and to be replied to, long enough for the
+// lightningd calls of a request queued behind those of others
+const (
+	httpReadHeaderTimeout = 10 * time.Second
+	httpWriteTimeout      = 3 * time.Minute
+)
+
+// WebServer serves a read-only dashboard of the node, in HTML for browsers
+// and in JSON under /api.
+type WebServer struct {
+	ui        *UI
+	token     string
+	templates *template.Template
+	// lightningd is asked one request at a time, which also keeps the
+	// caches of the client code to a single goroutine
+	lock sync.Mutex
+}
+
+type webRow struct {
+	Label template.HTML
+	Value template.HTML
+}
+
+type webPane struct {
+	Title string
+	Rows  []webRow
+}
+
+type webTable struct {
+	Title   string
+	Columns []template.HTML
+	Aligns  []string
+	Rows    [][]template.HTML
+	Totals  []template.HTML
+}
+
+type webPage struct {
+	Page    string
+	Title   string
+	Alias   string
+	Updated time.Time
+	Panes   []webPane
+	Table   webTable
+}
+
+// Serve serves the dashboard on listen until it fails. Without a token
+// anyone who can connect sees the node, so that is only allowed on loopback
+// addresses.
+func (ui *UI) Serve(listen, token string) error {
+	if token == "" && !loopbackAddress(listen) {
+		return fmt.Errorf("serving on %s needs a --token", listen)
+	}
+	templates, err := template.ParseFS(webAssets, "web/*.html")
+	if err != nil {
+		return err
+	}
+	s := &WebServer{ui: ui, token: token, templates: templates}
+
+	static, _ := fs.Sub(webAssets, "web")
+	mux := http.NewServeMux()
+	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.FS(static))))
+	mux.HandleFunc("/", s.handle(s.overview))
+	mux.HandleFunc("/channels", s.handle(s.channels))
+	mux.HandleFunc("/api/node", s.handle(s.apiNode))
+	mux.HandleFunc("/api/channels", s.handle(s.apiExport("channels")))
+	mux.HandleFunc("/api/activity", s.handle(s.apiExport("activity")))
+
+	ui.log.Info("Serving the dashboard on http://" + listen + "/\n")
+	return newHTTPServer(listen, mux).ListenAndServe()
+}
+
+// newHTTPServer serves handler on listen, with the timeouts of the web
+// dashboard and the exporter.
+func newHTTPServer(listen string, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              listen,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		WriteTimeout:      httpWriteTimeout,
+	}
+}
+
+func loopbackAddress(listen string) bool {
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// handle checks the token, given as a bearer token, a cookie, or once in
+// the token query parameter which is then swapped for the cookie.
+func (s *WebServer) handle(handler func(w http.ResponseWriter, r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "the dashboard is read-only", http.StatusMethodNotAllowed)
+			return
+		}
+		if s.token != "" {
+			if token := r.URL.Query().Get("token"); token != "" && s.validToken(token) {
+				http.SetCookie(w, &http.Cookie{
+					Name: tokenCookie, Value: token, Path: "/", HttpOnly: true, SameSite: http.SameSiteStrictMode,
+				})
+				query := r.URL.Query()
+				query.Del("token")
+				r.URL.RawQuery = query.Encode()
+				http.Redirect(w, r, r.URL.String(), http.StatusSeeOther)
+				return
+			}
+			if !s.authorized(r) {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "missing or wrong token", http.StatusUnauthorized)
+				return
+			}
+		}
+
+		s.lock.Lock()
+		defer s.lock.Unlock()
+		if err := handler(w, r); err != nil {
+			s.ui.log.Warn("Web request " + r.URL.Path + " failed: " + err.Error() + "\n")
+			status := http.StatusInternalServerError
+			if isConnectionError(err) {
+				status = http.StatusServiceUnavailable
+			}
+			http.Error(w, err.Error(), status)
+		}
+	}
+}
+
+func (s *WebServer) validToken(token string) bool {
+	return subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) == 1
+}
+
+func (s *WebServer) authorized(r *http.Request) bool {
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		return s.validToken(strings.TrimPrefix(auth, "Bearer "))
+	}
+	if cookie, err := r.Cookie(tokenCookie); err == nil {
+		return s.validToken(cookie.Value)
+	}
+	return false
+}
+
+func (s *WebServer) overview(w http.ResponseWriter, r *http.Request) error {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return nil
+	}
+	summary, err := getNodeSummary(s.ui)
+	if err != nil {
+		return err
+	}
+	activities, err := getActivities(s.ui, summary.ID, time.Now().Add(-activityPeriod))
+	if err != nil {
+		return err
+	}
+
+	page := webPage{Page: "overview", Title: "Overview", Alias: summary.Alias, Updated: time.Now()}
+	for _, pane := range dashPanes(s.ui, summary) {
+		p := webPane{Title: pane.title}
+		for _, row := range pane.column.rows {
+			p.Rows = append(p.Rows, webRow{
+				tviewHTML(pane.column.labelColor + row.label),
+				tviewHTML(pane.column.valueColor + row.value),
+			})
+		}
+		page.Panes = append(page.Panes, p)
+	}
+
+	showFiat := getSettings(s.ui).ShowFiat && priceProvider != nil
+	table := webTable{Title: "Recent LN activity"}
+	headers := []string{"date", "operation", "amount", "fees (" + amountUnit + ")"}
+	if showFiat {
+		headers = append(headers, "amount ("+priceProvider.Currency()+")")
+	}
+	headers = append(headers, "description")
+	totalFees := Msat(0)
+	for i, header := range headers {
+		table.Columns = append(table.Columns, tviewHTML(header))
+		switch {
+		case i == 0:
+			table.Aligns = append(table.Aligns, "center")
+		case i == len(headers)-1:
+			table.Aligns = append(table.Aligns, "left")
+		default:
+			table.Aligns = append(table.Aligns, "right")
+		}
+	}
+	for _, activity := range activities {
+		var row []template.HTML
+		for _, cell := range activityCells(activity, showFiat) {
+			row = append(row, tviewHTML(cell))
+		}
+		table.Rows = append(table.Rows, row)
+		totalFees += activity.fees
+	}
+	table.Totals = make([]template.HTML, len(headers))
+	table.Totals[3] = tviewHTML("[red]" + totalFees.Format())
+	page.Table = table
+
+	return s.templates.ExecuteTemplate(w, "overview", page)
+}
+
+func (s *WebServer) channels(w http.ResponseWriter, r *http.Request) error {
+	info, err := getInfo(s.ui)
+	if err != nil {
+		return err
+	}
+	allChannels, err := getChannels(s.ui)
+	if err != nil {
+		return err
+	}
+	channels := sortChannels(allChannels, getSettings(s.ui).channelSortKeys())
+
+	table := webTable{Title: fmt.Sprintf("Channels (%d)", len(channels))}
+	columns := visibleChannelColumns(s.ui)
+	hasTotals := false
+	for _, column := range columns {
+		table.Columns = append(table.Columns, tviewHTML(column.header))
+		table.Aligns = append(table.Aligns, webAlign(column.align))
+		hasTotals = hasTotals || column.total != nil
+	}
+	for _, channel := range channels {
+		var row []template.HTML
+		for _, column := range columns {
+			row = append(row, tviewHTML(column.cell(s.ui, channel)))
+		}
+		table.Rows = append(table.Rows, row)
+	}
+	if hasTotals {
+		for _, column := range columns {
+			var total template.HTML
+			if column.total != nil {
+				total = tviewHTML(column.total(channels))
+			}
+			table.Totals = append(table.Totals, total)
+		}
+	}
+
+	page := webPage{Page: "channels", Title: "Channels", Alias: info.Get("alias").String(), Updated: time.Now(), Table: table}
+	return s.templates.ExecuteTemplate(w, "channels", page)
+}
+
+func (s *WebServer) apiNode(w http.ResponseWriter, r *http.Request) error {
+	summary, err := getNodeSummary(s.ui)
+	if err != nil {
+		return err
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(summary)
+}
+
+// apiExport serves the export of a table, as cluster --export does.
+func (s *WebServer) apiExport(name string) func(w http.ResponseWriter, r *http.Request) error {
+	return func(w http.ResponseWriter, r *http.Request) error {
+		e, err := exporters[name](s.ui)
+		if err != nil {
+			return err
+		}
+		w.Header().Set("Content-Type", "application/json")
+		return e.WriteJSON(w)
+	}
+}
+
+func webAlign(align int) string {
+	switch align {
+	case tview.AlignCenter:
+		return "center"
+	case tview.AlignRight:
+		return "right"
+	}
+	return "left"
+}
+
+var tviewTagRe = regexp.MustCompile(`\[([a-zA-Z0-9#]*)(?::([a-zA-Z0-9#-]*))?(?::([a-zA-Z-]*))?\]`)
+
+// tviewHTML turns text with tview color tags, as the terminal pages show,
+// into HTML.
+func tviewHTML(s string) template.HTML {
+	var b strings.Builder
+	open := false
+	last := 0
+	for _, m := range tviewTagRe.FindAllStringSubmatchIndex(s, -1) {
+		b.WriteString(html.EscapeString(s[last:m[0]]))
+		last = m[1]
+		if m[0] == m[1]-2 {
+			// [] ends an escaped [tag[]
+			b.WriteString("]")
+			continue
+		}
+		color, attributes := s[m[2]:m[3]], ""
+		if m[6] >= 0 {
+			attributes = s[m[6]:m[7]]
+		}
+		var style []string
+		if color == "bold" {
+			style = append(style, "font-weight:bold")
+		} else if color != "" && color != "-" {
+			style = append(style, "color:"+color)
+		}
+		if strings.Contains(attributes, "b") {
+			style = append(style, "font-weight:bold")
+		}
+		if open {
+			b.WriteString("</span>")
+			open = false
+		}
+		if len(style) > 0 {
+			b.WriteString(`<span style="` + strings.Join(style, ";") + `">`)
+			open = true
+		}
+	}
+	b.WriteString(html.EscapeString(s[last:]))
+	if open {
+		b.WriteString("</span>")
+	}
+	return template.HTML(strings.ReplaceAll(strings.TrimPrefix(b.String(), "\n"), "\n", "<br>"))
+}